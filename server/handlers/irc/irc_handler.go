@@ -2,12 +2,18 @@ package irc
 
 import (
 	"chatto/irc"
+	"chatto/irc/format"
 	"context"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// joinTimeout bounds the wait for the JOIN of an invite, the server not
+// answering it otherwise leaving the handler hanging
+const joinTimeout = 10 * time.Second
+
 type Handler struct {
 	context context.Context
 }
@@ -16,61 +22,56 @@ func New(ctx context.Context) *Handler {
 	return &Handler{context: ctx}
 }
 
-func (h *Handler) Join(e irc.Event) {
-	client, args := e.Client, e.Message.Args
-	if len(args) < 1 {
+func (h *Handler) Join(e irc.JoinEvent) {
+	if !e.Self {
 		return
 	}
-	channel := args[0]
-	log.Infof("Joined channel %s", channel)
-	if err := client.Privmsg(h.context, channel, "Hello, world!"); err != nil {
-		log.Errorf("Failed to send message to %s: %+v", channel, err)
+	log.Infof("Joined channel %s", e.Channel)
+	if err := e.Client.Privmsg(h.context, e.Channel, "Hello, world!"); err != nil {
+		log.Errorf("Failed to send message to %s: %+v", e.Channel, err)
 	} else {
-		log.Infof("Sent hello message to %s", channel)
+		log.Infof("Sent hello message to %s", e.Channel)
 	}
 }
 
-func (h *Handler) Invite(e irc.Event) {
-	client, args := e.Client, e.Message.Args
-	if len(args) < 2 {
+func (h *Handler) Invite(e irc.InviteEvent) {
+	if !e.Self {
 		return
 	}
-	channel := args[1]
-	log.Infof("Invited to channel %s", channel)
-	if err := client.Join(h.context, channel); err != nil {
-		log.Errorf("Failed to join channel %s: %+v", channel, err)
+	log.Infof("Invited to channel %s", e.Channel)
+	ctx, cancel := context.WithTimeout(h.context, joinTimeout)
+	defer cancel()
+	if err := e.Client.Join(ctx, e.Channel); err != nil {
+		log.Errorf("Failed to join channel %s: %+v", e.Channel, err)
 	}
 }
 
-func (h *Handler) Kick(e irc.Event) {
-	args := e.Message.Args
-	nargs := len(args)
-	if nargs < 1 {
+func (h *Handler) Kick(e irc.KickEvent) {
+	if !e.Self {
 		return
 	}
-	channel := args[0]
-	if nargs >= 3 {
-		reason := args[2]
-		log.Infof("Kicked from channel %s (%s)", channel, reason)
+	if e.Reason != "" {
+		log.Infof("Kicked from channel %s (%s)", e.Channel, e.Reason)
 	} else {
-		log.Infof("Kicked from channel %s", channel)
+		log.Infof("Kicked from channel %s", e.Channel)
 	}
 }
 
-func (h *Handler) Message(e irc.Event) {
-	client := e.Client
-	nick, args := e.Message.Nick, e.Message.Args
-	if len(args) < 2 {
+func (h *Handler) Message(e irc.PrivmsgEvent) {
+	nick, channel, message := e.User.Nick, e.Target, e.Text
+	if e.IsCTCP {
+		if e.CTCP.Command == irc.CTCP_ACTION {
+			log.Infof("[%s] * %s %s", channel, nick, format.Strip(message))
+		}
 		return
 	}
-	channel, message := args[0], strings.Join(args[1:], " ")
-	log.Infof("[%s] %s: %s", channel, nick, message)
+	log.Infof("[%s] %s: %s", channel, nick, format.Strip(message))
 	if !strings.HasPrefix(message, ".echo") {
 		return
 	}
 	sidx := strings.Index(message, " ")
 	reply := strings.TrimSpace(message[sidx+1:])
-	if err := client.Privmsg(h.context, channel, reply); err != nil {
+	if err := e.Reply(h.context, reply); err != nil {
 		log.Errorf("Failed to reply echo message to %s at %s: %+v", nick, channel, err)
 	} else {
 		log.Infof("Replied echo message to %s at %s", nick, channel)
@@ -0,0 +1,83 @@
+package irc
+
+import (
+	"chatto/irc"
+	utesting "chatto/util/testing"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	require := require.New(t)
+	ctx, cancel := utesting.CreateTestingContext()
+	defer cancel()
+
+	srv := utesting.NewIRCServer()
+	defer srv.Close()
+	c := irc.NewClient(irc.Config{Nick: "chatto", Flood: irc.FloodConfig{Burst: -1}})
+	require.Nil(c.Connect(ctx, srv.Pipe()))
+	sess, err := srv.WaitSession("chatto", time.Second)
+	require.Nil(err)
+
+	handler := New(ctx)
+	c.OnJoin(ctx, handler.Join)
+	c.OnPrivmsg(ctx, handler.Message)
+
+	// Greets the channel once joined
+	require.Nil(c.Join(ctx, "#chatto"))
+	msg, err := sess.WaitFor(irc.PRIVMSG, time.Second)
+	require.Nil(err)
+	require.Equal([]string{"#chatto", "Hello, world!"}, msg.Params)
+
+	// Echoes the messages of the other users
+	alice := srv.AddUser("alice")
+	alice.Exec("JOIN #chatto")
+	alice.Exec("PRIVMSG #chatto :.echo Hi there")
+	msg, err = sess.WaitFor(irc.PRIVMSG, time.Second)
+	require.Nil(err)
+	require.Equal([]string{"#chatto", "Hi there"}, msg.Params)
+
+	require.Nil(c.Close(ctx))
+}
+
+// TestTranscripts replays the transcripts of testdata, which must match the
+// lines sent by the handlers.
+func TestTranscripts(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.transcript")
+	require.Nil(t, err)
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			require := require.New(t)
+			ctx, cancel := utesting.CreateTestingContext()
+			defer cancel()
+
+			f, err := os.Open(file)
+			require.Nil(err)
+			defer f.Close()
+			entries, err := irc.ReadTranscript(f)
+			require.Nil(err)
+
+			c := irc.NewClient(irc.Config{
+				Nick:      "chatto",
+				Flood:     irc.FloodConfig{Burst: -1},
+				Keepalive: irc.KeepaliveConfig{Interval: -1},
+			})
+			handler := New(ctx)
+			c.OnJoin(ctx, handler.Join)
+			c.OnInvite(ctx, handler.Invite)
+			c.OnKick(ctx, handler.Kick)
+			c.OnPrivmsg(ctx, handler.Message)
+
+			replayer := irc.NewReplayer(entries)
+			defer replayer.Close()
+			require.Nil(c.Connect(ctx, replayer))
+			require.Nil(replayer.Wait(ctx))
+			require.Empty(replayer.Unexpected())
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package irc
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	CAP_LS   = "LS"
+	CAP_LIST = "LIST"
+	CAP_REQ  = "REQ"
+	CAP_ACK  = "ACK"
+	CAP_NAK  = "NAK"
+	CAP_NEW  = "NEW"
+	CAP_DEL  = "DEL"
+	CAP_END  = "END"
+)
+
+// capabilities keeps track of the capabilities advertised by the server and
+// the ones currently enabled on the connection.
+type capabilities struct {
+	mu        sync.RWMutex
+	available map[string]string
+	enabled   map[string]bool
+}
+
+func newCapabilities() *capabilities {
+	return &capabilities{
+		available: make(map[string]string),
+		enabled:   make(map[string]bool),
+	}
+}
+
+func (c *capabilities) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.available = make(map[string]string)
+	c.enabled = make(map[string]bool)
+}
+
+// advertise records the capabilities from a CAP LS or CAP NEW reply.
+func (c *capabilities) advertise(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cap := range strings.Fields(s) {
+		name, value := cap, ""
+		if idx := strings.Index(cap, "="); idx != -1 {
+			name, value = cap[:idx], cap[idx+1:]
+		}
+		c.available[name] = value
+	}
+}
+
+// withdraw removes the capabilities from a CAP DEL reply.
+func (c *capabilities) withdraw(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range strings.Fields(s) {
+		delete(c.available, name)
+		delete(c.enabled, name)
+	}
+}
+
+// acknowledge applies the capabilities from a CAP ACK reply.
+func (c *capabilities) acknowledge(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range strings.Fields(s) {
+		if strings.HasPrefix(name, "-") {
+			delete(c.enabled, name[1:])
+		} else {
+			c.enabled[name] = true
+		}
+	}
+}
+
+// wanted returns the desired capabilities which are available but not enabled yet.
+func (c *capabilities) wanted(desired []string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make([]string, 0)
+	for _, name := range desired {
+		if _, ok := c.available[name]; ok && !c.enabled[name] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+func (c *capabilities) value(name string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.available[name]
+	return v, ok
+}
+
+func (c *capabilities) has(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.enabled[name]
+}
+
+func (c *capabilities) list() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make([]string, 0, len(c.enabled))
+	for name := range c.enabled {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// HasCap reports whether the capability has been enabled on the connection.
+func (c *Client) HasCap(name string) bool {
+	return c.caps.has(name)
+}
+
+// CapValue returns the value advertised by the server for the capability.
+func (c *Client) CapValue(name string) (string, bool) {
+	return c.caps.value(name)
+}
+
+// Caps returns the sorted list of enabled capabilities.
+func (c *Client) Caps() []string {
+	return c.caps.list()
+}
@@ -0,0 +1,84 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapNegotiation(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{
+		Nick: "chatto",
+		Caps: []string{"multi-prefix", "away-notify", "server-time"},
+	})
+	err := connectClient(c, conn, func() {
+		srv.expect("CAP LS 302")
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test CAP * LS * :multi-prefix sasl=PLAIN,EXTERNAL",
+			":irc.test CAP * LS :server-time cap-notify",
+		)
+		srv.expect("CAP REQ :multi-prefix server-time")
+		srv.send(":irc.test CAP * ACK :multi-prefix server-time")
+		srv.expect("CAP END")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+	require.Equal([]string{"multi-prefix", "server-time"}, c.Caps())
+	value, ok := c.CapValue("sasl")
+	require.True(ok)
+	require.Equal("PLAIN,EXTERNAL", value)
+
+	// Capabilities advertised later on get requested as well
+	srv.send(":irc.test CAP chatto NEW :away-notify")
+	srv.expect("CAP REQ :away-notify")
+	srv.send(":irc.test CAP chatto ACK :away-notify")
+	require.Eventually(func() bool {
+		return c.HasCap("away-notify")
+	}, time.Second, 10*time.Millisecond)
+
+	// Withdrawn capabilities are no longer enabled
+	srv.send(":irc.test CAP chatto DEL :multi-prefix")
+	require.Eventually(func() bool {
+		return !c.HasCap("multi-prefix")
+	}, time.Second, 10*time.Millisecond)
+
+	require.Nil(closeClient(c, srv))
+}
+
+func TestCapNotSupported(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{
+		Nick: "chatto",
+		Caps: []string{"multi-prefix"},
+	})
+	err := connectClient(c, conn, func() {
+		srv.expect("CAP LS 302")
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 421 * CAP :Unknown command",
+			":irc.test 433 * chatto :Nickname is already in use",
+		)
+		srv.expect("NICK chatto_")
+		srv.send(
+			":irc.test 001 chatto_ :Welcome",
+			":irc.test 376 chatto_ :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+	require.Empty(c.Caps())
+	require.Nil(closeClient(c, srv))
+}
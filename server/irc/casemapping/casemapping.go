@@ -0,0 +1,70 @@
+// Package casemapping compares nicks and channel names the way IRC servers do,
+// following the CASEMAPPING token of RPL_ISUPPORT.
+package casemapping
+
+import "strings"
+
+type CaseMapping string
+
+const (
+	// ASCII only folds the letters A to Z
+	ASCII CaseMapping = "ascii"
+	// RFC1459 also folds []\~ to {}|^, the default for servers which don't advertise one
+	RFC1459 CaseMapping = "rfc1459"
+	// RFC1459Strict is RFC1459 without folding ~ to ^
+	RFC1459Strict CaseMapping = "rfc1459-strict"
+)
+
+// Parse returns the casemapping named by the CASEMAPPING token, falling back to
+// RFC1459 when the name is unknown.
+func Parse(name string) CaseMapping {
+	switch m := CaseMapping(strings.ToLower(name)); m {
+	case ASCII, RFC1459, RFC1459Strict:
+		return m
+	}
+	return RFC1459
+}
+
+// Fold returns the lowercase form of the name under the casemapping.
+func (m CaseMapping) Fold(name string) string {
+	// Return the name as is when there's nothing to fold
+	i := 0
+	for i < len(name) && m.foldByte(name[i]) == name[i] {
+		i++
+	}
+	if i >= len(name) {
+		return name
+	}
+	b := []byte(name)
+	for ; i < len(b); i++ {
+		b[i] = m.foldByte(b[i])
+	}
+	return string(b)
+}
+
+// Equal reports whether both names are the same under the casemapping.
+func (m CaseMapping) Equal(a string, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if m.foldByte(a[i]) != m.foldByte(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m CaseMapping) foldByte(c byte) byte {
+	switch {
+	case c >= 'A' && c <= 'Z':
+		return c + 'a' - 'A'
+	case m == ASCII:
+		return c
+	case c == '[', c == ']', c == '\\':
+		return c + '{' - '['
+	case c == '~' && m != RFC1459Strict:
+		return '^'
+	}
+	return c
+}
@@ -0,0 +1,76 @@
+package casemapping
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaseMapping(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(RFC1459, Parse(""))
+	assert.Equal(RFC1459, Parse("unknown"))
+	assert.Equal(ASCII, Parse("ascii"))
+	assert.Equal(RFC1459Strict, Parse("RFC1459-strict"))
+
+	assert.Equal("#chatto", RFC1459.Fold("#Chatto"))
+	assert.Equal("nick{}|^", RFC1459.Fold("NICK[]\\~"))
+	assert.Equal("nick{}|~", RFC1459Strict.Fold("NICK[]\\~"))
+	assert.Equal("nick[]\\~", ASCII.Fold("NICK[]\\~"))
+	// Only ASCII letters are folded
+	assert.Equal("ünïcode", RFC1459.Fold("ünïcode"))
+
+	assert.True(RFC1459.Equal("Nick[away]", "nick{AWAY}"))
+	assert.False(ASCII.Equal("Nick[away]", "nick{AWAY}"))
+	assert.True(RFC1459.Equal("a~", "A^"))
+	assert.False(RFC1459Strict.Equal("a~", "A^"))
+	assert.False(RFC1459.Equal("nick", "nick_"))
+}
+
+func TestMap(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMap(RFC1459)
+
+	m.Set("#Chatto", "key")
+	m.Set("Nick[m]", 1)
+	value, ok := m.Get("#CHATTO")
+	assert.True(ok)
+	assert.Equal("key", value)
+	assert.True(m.Has("nick{M}"))
+	assert.Equal(2, m.Len())
+
+	// The key is kept as first set
+	m.Set("#chatto", "other")
+	key, ok := m.Key("#chatto")
+	assert.True(ok)
+	assert.Equal("#Chatto", key)
+	assert.Equal([]string{"#Chatto", "Nick[m]"}, m.Keys())
+
+	assert.True(m.Rename("nick{m}", "Nick"))
+	assert.False(m.Has("Nick[m]"))
+	value, _ = m.Get("NICK")
+	assert.Equal(1, value)
+
+	keys := make([]string, 0)
+	m.Each(func(key string, _ interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal([]string{"#Chatto", "Nick"}, keys)
+
+	assert.True(m.Delete("#CHATTO"))
+	assert.False(m.Delete("#chatto"))
+	assert.Equal(1, m.Len())
+
+	// Switching the casemapping refolds the keys
+	m.Set("Away[]", true)
+	assert.True(m.Has("away{}"))
+	m.SetMapping(ASCII)
+	assert.Equal(ASCII, m.Mapping())
+	assert.False(m.Has("away{}"))
+	assert.True(m.Has("AWAY[]"))
+
+	m.Clear()
+	assert.Equal(0, m.Len())
+}
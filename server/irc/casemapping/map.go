@@ -0,0 +1,155 @@
+package casemapping
+
+import (
+	"sort"
+	"sync"
+)
+
+type entry struct {
+	key   string
+	value interface{}
+}
+
+// Map is a map keyed by nicks or channel names which ignores their case, while
+// still remembering the keys as they were first set. It is safe for concurrent use.
+type Map struct {
+	mu      sync.RWMutex
+	mapping CaseMapping
+	entries map[string]entry
+}
+
+func NewMap(mapping CaseMapping) *Map {
+	return &Map{
+		mapping: mapping,
+		entries: make(map[string]entry),
+	}
+}
+
+// Get returns the value stored for the key.
+func (m *Map) Get(key string) (interface{}, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.entries[m.mapping.Fold(key)]
+	return e.value, ok
+}
+
+func (m *Map) Has(key string) bool {
+	_, ok := m.Get(key)
+	return ok
+}
+
+// Key returns the key as it was stored, like "#Chatto" for "#chatto".
+func (m *Map) Key(key string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.entries[m.mapping.Fold(key)]
+	return e.key, ok
+}
+
+// Set stores the value, keeping the original key when it was already set.
+func (m *Map) Set(key string, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	folded := m.mapping.Fold(key)
+	if e, ok := m.entries[folded]; ok {
+		key = e.key
+	}
+	m.entries[folded] = entry{key: key, value: value}
+}
+
+// Rename moves the value to a new key, which is useful on nick changes.
+func (m *Map) Rename(from string, to string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[m.mapping.Fold(from)]
+	if !ok {
+		return false
+	}
+	delete(m.entries, m.mapping.Fold(from))
+	m.entries[m.mapping.Fold(to)] = entry{key: to, value: e.value}
+	return true
+}
+
+func (m *Map) Delete(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	folded := m.mapping.Fold(key)
+	if _, ok := m.entries[folded]; !ok {
+		return false
+	}
+	delete(m.entries, folded)
+	return true
+}
+
+func (m *Map) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries)
+}
+
+// Keys returns the stored keys in sorted order.
+func (m *Map) Keys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, 0, len(m.entries))
+	for _, e := range m.entries {
+		keys = append(keys, e.key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Each calls the function for every entry in key order until it returns false.
+func (m *Map) Each(fn func(key string, value interface{}) bool) {
+	m.mu.RLock()
+	entries := make([]entry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, e)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	for _, e := range entries {
+		if !fn(e.key, e.value) {
+			return
+		}
+	}
+}
+
+func (m *Map) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = make(map[string]entry)
+}
+
+// Mapping returns the casemapping used to compare keys.
+func (m *Map) Mapping() CaseMapping {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mapping
+}
+
+// SetMapping switches to another casemapping, for example once the server
+// advertises its CASEMAPPING. Keys which become equal are merged, keeping the
+// last one in key order.
+func (m *Map) SetMapping(mapping CaseMapping) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mapping == m.mapping {
+		return
+	}
+	m.mapping = mapping
+	keys := make([]string, 0, len(m.entries))
+	for folded := range m.entries {
+		keys = append(keys, folded)
+	}
+	sort.Strings(keys)
+	entries := make(map[string]entry, len(m.entries))
+	for _, folded := range keys {
+		e := m.entries[folded]
+		entries[mapping.Fold(e.key)] = e
+	}
+	m.entries = entries
+}
@@ -0,0 +1,150 @@
+// Package charset converts the lines of legacy IRC networks between UTF-8 and
+// the single-byte charsets still in use on them.
+package charset
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset is UTF-8 or a single-byte charset.
+type Charset struct {
+	name    string
+	aliases []string
+	// decode maps the bytes from 0x80 to runes, nil for UTF-8
+	decode *[128]rune
+	encode map[rune]byte
+}
+
+var (
+	UTF8   = &Charset{name: "UTF-8", aliases: []string{"utf8"}}
+	Latin1 = newCharset("ISO-8859-1", []string{"latin1", "latin-1", "iso8859-1", "l1"}, latin1High())
+	CP1252 = newCharset("windows-1252", []string{"cp1252"}, cp1252High())
+	CP1251 = newCharset("windows-1251", []string{"cp1251"}, cp1251High())
+)
+
+var charsets = []*Charset{UTF8, Latin1, CP1252, CP1251}
+
+func newCharset(name string, aliases []string, high [128]rune) *Charset {
+	c := &Charset{name: name, aliases: aliases, decode: &high, encode: make(map[rune]byte)}
+	for i, r := range high {
+		c.encode[r] = byte(0x80 + i)
+	}
+	return c
+}
+
+// Lookup returns the charset by name or alias, ignoring the case.
+func Lookup(name string) (*Charset, bool) {
+	for _, c := range charsets {
+		if strings.EqualFold(c.name, name) {
+			return c, true
+		}
+		for _, alias := range c.aliases {
+			if strings.EqualFold(alias, name) {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (c *Charset) Name() string {
+	return c.name
+}
+
+func (c *Charset) String() string {
+	return c.name
+}
+
+func (c *Charset) IsUTF8() bool {
+	return c.decode == nil
+}
+
+// Decode converts the text to UTF-8, invalid UTF-8 sequences being replaced
+// when the charset is UTF-8.
+func (c *Charset) Decode(s string) string {
+	if c.IsUTF8() {
+		return strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	if isASCII(s) {
+		return s
+	}
+	sb := strings.Builder{}
+	sb.Grow(len(s) * 2)
+	for i := 0; i < len(s); i++ {
+		if b := s[i]; b < utf8.RuneSelf {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteRune(c.decode[b-0x80])
+		}
+	}
+	return sb.String()
+}
+
+// Encode converts the UTF-8 text to the charset, the characters it can't
+// represent being replaced with a question mark.
+func (c *Charset) Encode(s string) string {
+	if c.IsUTF8() || isASCII(s) {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < utf8.RuneSelf:
+			b = append(b, byte(r))
+		case c.encode[r] != 0:
+			b = append(b, c.encode[r])
+		default:
+			b = append(b, '?')
+		}
+	}
+	return string(b)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func latin1High() [128]rune {
+	high := [128]rune{}
+	for i := range high {
+		high[i] = rune(0x80 + i)
+	}
+	return high
+}
+
+func cp1252High() [128]rune {
+	high := latin1High()
+	// The bytes left undefined keep their C1 control code
+	copy(high[:0x20], []rune{
+		0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+		0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+	})
+	return high
+}
+
+func cp1251High() [128]rune {
+	high := [128]rune{}
+	copy(high[:0x40], []rune{
+		0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+		0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0x0098, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+		0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+		0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+		0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+		0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+	})
+	// А to я
+	for i := 0x40; i < len(high); i++ {
+		high[i] = rune(0x0410 + i - 0x40)
+	}
+	return high
+}
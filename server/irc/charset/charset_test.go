@@ -0,0 +1,45 @@
+package charset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	assert := assert.New(t)
+	for name, expected := range map[string]*Charset{
+		"utf-8":        UTF8,
+		"UTF8":         UTF8,
+		"latin1":       Latin1,
+		"ISO-8859-1":   Latin1,
+		"cp1252":       CP1252,
+		"Windows-1251": CP1251,
+	} {
+		c, ok := Lookup(name)
+		assert.True(ok, name)
+		assert.Equal(expected, c, name)
+	}
+	_, ok := Lookup("ebcdic")
+	assert.False(ok)
+}
+
+func TestCharsets(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("café", Latin1.Decode("caf\xe9"))
+	assert.Equal("caf\xe9", Latin1.Encode("café"))
+	assert.Equal("€5 – naïve", CP1252.Decode("\x805 \x96 na\xefve"))
+	assert.Equal("\x805 \x96 na\xefve", CP1252.Encode("€5 – naïve"))
+	assert.Equal("Привет, Ёж №1", CP1251.Decode("\xcf\xf0\xe8\xe2\xe5\xf2, \xa8\xe6 \xb91"))
+	assert.Equal("\xcf\xf0\xe8\xe2\xe5\xf2, \xa8\xe6 \xb91", CP1251.Encode("Привет, Ёж №1"))
+
+	// Characters missing from the charset are replaced
+	assert.Equal("? ?", Latin1.Encode("€ 日"))
+	assert.Equal("ASCII only", CP1251.Encode("ASCII only"))
+
+	assert.True(UTF8.IsUTF8())
+	assert.False(Latin1.IsUTF8())
+	assert.Equal("héllo", UTF8.Encode("héllo"))
+	assert.Equal("h�llo", UTF8.Decode("h\xe9llo"))
+}
@@ -2,12 +2,15 @@ package irc
 
 import (
 	"bufio"
+	"chatto/irc/casemapping"
+	"chatto/irc/charset"
 	"chatto/util/stream"
 	"context"
 	"errors"
 	"io"
 	"strings"
 	"sync"
+	"time"
 )
 
 type HandlerFunc func(Event)
@@ -15,29 +18,80 @@ type HandlerFunc func(Event)
 var (
 	ErrAlreadyConnected = errors.New("already connected")
 	ErrNotConnected     = errors.New("not connected")
+	ErrConnectionLost   = errors.New("connection lost")
 )
 
 type Config struct {
 	Nick  string
 	Ident string
 	Name  string
+	// UserMode is the mode bitmask sent with USER, defaults to 12 for +iw
+	UserMode string
+	// Password is sent with PASS before registering when set
+	Password string
+	// WebIRC sends the WEBIRC command first when connecting through a gateway
+	WebIRC *WebIRCConfig
+
+	// Nicks configures the nicks tried when the nick is unavailable
+	Nicks NickConfig
+	// Caps lists the capabilities to request from the server when available
+	Caps []string
+	// SASL enables authentication during registration when set
+	SASL *SASLConfig
+	// TLS enables implicit TLS when connecting through Conn
+	TLS *TLSConfig
+	// Reconnect configures the backoff used by Conn.Run
+	Reconnect ReconnectConfig
+	// Keepalive configures the client PINGs used to detect dead connections
+	Keepalive KeepaliveConfig
+	// Flood configures the rate limit of outgoing lines
+	Flood FloodConfig
+	// SplitWords makes long messages break at word boundaries when possible
+	SplitWords bool
+	// CTCP configures the automatic replies to the CTCP queries
+	CTCP CTCPConfig
+	// Encoding configures the charsets of the networks which don't use UTF-8
+	Encoding EncodingConfig
+	// Transcript records the lines sent and received when set, see ReadTranscript
+	Transcript io.Writer
 }
 
 type Client struct {
 	*Commands
-	stream *stream.Stream
-
-	cfg  Config
-	nick string
+	stream    *stream.Stream
+	caps      *capabilities
+	keepalive *keepalive
+	queue     *sendQueue
+	isupport  *ISupport
+	state     *channelStore
+	decoder   *replyDecoder
+	// ctcpReplies holds the automatic CTCP replies by command
+	ctcpReplies map[string]CTCPReplyFunc
+	ctcpLimiter *ctcpLimiter
+
+	cfg      Config
+	nick     string
+	prefNick string
+	// pendingNick is the nick asked for with Nick until the server accepts it
+	pendingNick string
+	user        string
+	host        string
+	// userModes holds the modes set on our own user
+	userModes string
+	// channels holds the key of each joined channel
+	channels *casemapping.Map
 
 	out    chan string
 	cancel context.CancelFunc
+	done   chan struct{}
 
 	mu sync.RWMutex
 	wg sync.WaitGroup
 
-	connected bool
-	lastError error
+	connected  bool
+	registered bool
+	closing    bool
+	lastError  error
 }
 
 func NewClient(cfg Config) *Client {
@@ -47,15 +101,81 @@ func NewClient(cfg Config) *Client {
 	if cfg.Name == "" {
 		cfg.Name = "Chatto IRC client"
 	}
+	if cfg.UserMode == "" {
+		cfg.UserMode = defaultUserMode
+	}
+	if cfg.Keepalive.Interval == 0 {
+		cfg.Keepalive.Interval = defaultPingInterval
+	}
+	if cfg.Keepalive.Timeout <= 0 {
+		cfg.Keepalive.Timeout = defaultPingTimeout
+	}
+	if cfg.Flood.Burst == 0 {
+		cfg.Flood.Burst = defaultFloodBurst
+	}
+	if cfg.Flood.Interval <= 0 {
+		cfg.Flood.Interval = defaultFloodInterval
+	}
+	if cfg.CTCP.Burst == 0 {
+		cfg.CTCP.Burst = defaultCTCPBurst
+	}
+	if cfg.CTCP.Interval <= 0 {
+		cfg.CTCP.Interval = defaultCTCPInterval
+	}
+	if cfg.Nicks.Strategy == nil {
+		cfg.Nicks.Strategy = UnderscoreNicks
+	}
+	if cfg.Nicks.MaxAttempts <= 0 {
+		cfg.Nicks.MaxAttempts = defaultMaxNickAttempts
+	}
+	if cfg.Nicks.ReclaimInterval <= 0 {
+		cfg.Nicks.ReclaimInterval = defaultReclaimInterval
+	}
+	if cfg.Encoding.Charset == nil {
+		cfg.Encoding.Charset = charset.UTF8
+	}
+	if cfg.Encoding.Fallback == nil {
+		cfg.Encoding.Fallback = charset.Latin1
+	}
+	if cfg.SASL != nil && cfg.SASL.Mechanism == "" {
+		sasl := *cfg.SASL
+		sasl.Mechanism = SASL_PLAIN
+		cfg.SASL = &sasl
+	}
 	stream := stream.NewStream()
 	out := make(chan string)
-	return &Client{
-		Commands:  NewCommands(stream, out),
-		stream:    stream,
-		cfg:       cfg,
-		out:       out,
-		connected: false,
+	done := make(chan struct{})
+	close(done)
+	isupport := newISupport()
+	commands := NewCommands(stream, out)
+	commands.splitWords = cfg.SplitWords
+	commands.userMode = cfg.UserMode
+	commands.equalFold = isupport.EqualFold
+	c := &Client{
+		Commands:    commands,
+		stream:      stream,
+		caps:        newCapabilities(),
+		keepalive:   &keepalive{},
+		queue:       newSendQueue(cfg.Flood, isupport.Fold),
+		isupport:    isupport,
+		state:       newChannelStore(isupport),
+		decoder:     newReplyDecoder(),
+		ctcpReplies: ctcpReplies(cfg.CTCP),
+		ctcpLimiter: newCTCPLimiter(cfg.CTCP),
+		cfg:         cfg,
+		prefNick:    cfg.Nick,
+		channels:    casemapping.NewMap(isupport.Mapping()),
+		out:         out,
+		done:        done,
+		connected:   false,
+	}
+	commands.lineLimits = c.lineLimits
+	commands.self = c.isSelf
+	commands.labeled = func() bool {
+		return c.caps.has("labeled-response")
 	}
+	commands.done = c.Done
+	return c
 }
 
 func (c *Client) Connected() bool {
@@ -65,57 +185,60 @@ func (c *Client) Connected() bool {
 }
 
 func (c *Client) Connect(ctx context.Context, rw io.ReadWriter) error {
+	if c.cfg.Transcript != nil {
+		rw = NewRecorder(rw, c.cfg.Transcript)
+	}
 	if err := c.init(rw); err != nil {
 		return err
 	}
 
 	c.stream.Open()
-	ch := make(chan bool)
-	obs := c.Each(ctx, RAW, func(e Event) {
-		switch e.Message.Cmd {
-		case "001":
-			ch <- true
-		case ERR_NICKNAMEINUSE:
-			ch <- false
-		}
-	})
-	defer obs.Remove()
-
-	nick := c.cfg.Nick
-	for {
-		if err := c.Nick(ctx, nick); err != nil {
-			return err
-		}
-		if err := c.User(ctx, c.cfg.Ident, c.cfg.Name); err != nil {
-			return err
-		}
-		if <-ch {
-			break
-		}
-		nick = nick + "_"
+	if err := c.register(ctx); err != nil {
+		c.abort()
+		return err
 	}
 
 	c.mu.Lock()
-	c.nick = nick
+	c.registered = true
 	c.mu.Unlock()
-
 	c.notify(CONNECTED)
 	return nil
 }
 
 func (c *Client) Close(ctx context.Context) error {
-	if !c.Connected() {
+	c.mu.Lock()
+	if !c.connected {
+		c.mu.Unlock()
 		return ErrNotConnected
 	}
-	if err := c.Quit(ctx); err != nil {
-		return err
-	}
-	if err := c.terminate(ctx); err != nil {
-		return err
+	c.closing = true
+	c.mu.Unlock()
+
+	// Wait for the server to close the link, tearing down the connection right
+	// away could drop the QUIT before it is sent. The connection is torn down
+	// even when the wait fails, which is only reported afterwards.
+	_, err := c.CommandWait(ctx, QUIT)
+	if termErr := c.terminate(ctx); err == nil {
+		err = termErr
 	}
+	c.resetSession()
 	c.notify(DISCONNECTED)
 	c.stream.Close()
-	return nil
+	return err
+}
+
+// Done returns a channel which is closed once the current connection ends.
+func (c *Client) Done() <-chan struct{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.done
+}
+
+// Err returns the error which caused the last connection to be lost.
+func (c *Client) Err() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastError
 }
 
 func (c *Client) Once(ctx context.Context, name string, handler HandlerFunc) *stream.Observer {
@@ -143,30 +266,45 @@ func (c *Client) init(rw io.ReadWriter) error {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	c.cancel = cancel
+	c.done = make(chan struct{})
 	c.lastError = nil
+	c.registered = false
+	c.closing = false
+	c.user = ""
+	c.host = ""
+	c.userModes = ""
+	c.caps.reset()
+	c.keepalive.reset()
+	c.queue.reset()
+	c.isupport.reset()
+	c.state.reset()
+	c.decoder.reset()
 
 	c.wg.Add(2)
 	go c.recv(ctx, rw)
 	go c.send(ctx, rw)
+	if c.cfg.Keepalive.Interval > 0 {
+		c.wg.Add(1)
+		go c.runKeepalive(ctx)
+	}
 	registerInternalHandlers(ctx, c)
 
 	c.connected = true
 	return nil
 }
 
+// terminate stops the connection, which may already have been ended by the
+// server, and waits for its goroutines to finish.
 func (c *Client) terminate(ctx context.Context) (err error) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	if !c.connected {
-		return ErrNotConnected
-	}
+	c.shutdown()
+	c.mu.Unlock()
 
 	closeCtx, cancel := context.WithCancel(ctx)
 	go func() {
 		c.wg.Wait()
 		cancel()
 	}()
-	c.cancel()
 
 	// Wait for either the goroutines to finish or parent context being cancelled
 	select {
@@ -174,9 +312,55 @@ func (c *Client) terminate(ctx context.Context) (err error) {
 	case <-ctx.Done():
 		err = ctx.Err()
 	}
+	return err
+}
+
+// abort stops the connection without waiting for the goroutines to finish,
+// since the reader is only unblocked once the underlying stream gets closed.
+func (c *Client) abort() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shutdown()
+}
+
+// disconnect tears down a connection which ended without being closed and
+// notifies the handlers with the cause.
+func (c *Client) disconnect(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	c.mu.Lock()
+	if !c.connected {
+		c.mu.Unlock()
+		return
+	}
+	if c.closing {
+		// The server ended the connection after our QUIT
+		c.shutdown()
+		c.mu.Unlock()
+		return
+	}
+	if c.lastError == nil {
+		c.lastError = ErrConnectionLost
+	}
+	err = c.lastError
+	registered := c.registered
+	c.shutdown()
+	c.mu.Unlock()
+
+	if registered {
+		c.stream.Notify(DISCONNECTED, stream.Item{E: err})
+	}
+}
 
+// shutdown stops the connection goroutines, the caller must hold the lock.
+func (c *Client) shutdown() {
+	if !c.connected {
+		return
+	}
+	c.cancel()
+	close(c.done)
 	c.connected = false
-	return err
 }
 
 func (c *Client) recv(ctx context.Context, r io.Reader) {
@@ -186,6 +370,7 @@ func (c *Client) recv(ctx context.Context, r io.Reader) {
 		s, err := reader.ReadString('\n')
 		if err != nil {
 			c.handleError(err)
+			c.disconnect(ctx, err)
 			return
 		}
 		c.handleLine(strings.Trim(s, "\r\n"))
@@ -200,26 +385,63 @@ func (c *Client) recv(ctx context.Context, r io.Reader) {
 func (c *Client) send(ctx context.Context, w io.Writer) {
 	defer c.wg.Done()
 	writer := bufio.NewWriter(w)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 	for {
-		select {
-		case payload := <-c.out:
+		// Send as many queued lines as the flood control allows
+		for {
+			payload, wait, ok := c.queue.next(time.Now())
+			if !ok {
+				if wait > 0 {
+					resetTimer(timer, wait)
+				}
+				break
+			}
 			if _, err := writer.WriteString(payload); err != nil {
 				c.handleError(err)
+				c.disconnect(ctx, err)
 				return
 			}
 			if err := writer.Flush(); err != nil {
 				c.handleError(err)
+				c.disconnect(ctx, err)
 				return
 			}
+		}
+		select {
+		case payload := <-c.out:
+			c.queue.push(c.encodeLine(payload))
+		case <-timer.C:
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
 func (c *Client) handleLine(line string) {
-	msg := parseLine(line)
+	c.keepalive.received()
+	msg := parseLine(c.decodeLine(line))
+	if msg.Cmd == RPL_WELCOME && len(msg.Args) > 0 && msg.Args[0] != "" {
+		// Known before any observer runs so the replies to commands sent early
+		// are recognized as ours
+		c.mu.Lock()
+		c.nick = msg.Args[0]
+		c.mu.Unlock()
+	}
 	c.notify(RAW, msg)
+	if name, event, ok := c.ctcpEvent(msg); ok {
+		c.notifyEvent(name, event)
+	}
 	if msg.Cmd != "" {
 		c.notify(msg.Cmd, msg)
 	}
@@ -233,6 +455,10 @@ func (c *Client) handleError(err error) {
 	}
 }
 
+func (c *Client) notifyEvent(name string, event Event) {
+	c.stream.Notify(name, stream.Item{V: event})
+}
+
 func (c *Client) notify(name string, messages ...Message) {
 	if len(messages) <= 0 {
 		c.stream.Notify(name, stream.Item{})
@@ -4,61 +4,265 @@ import (
 	"chatto/util/stream"
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
 	CONNECTED    = "CONNECTED"
 	DISCONNECTED = "DISCONNECTED"
 	PRIVMSG      = "PRIVMSG"
+	NOTICE       = "NOTICE"
+	TAGMSG       = "TAGMSG"
 	NICK         = "NICK"
 	USER         = "USER"
+	PASS         = "PASS"
+	WEBIRC       = "WEBIRC"
 	JOIN         = "JOIN"
 	INVITE       = "INVITE"
 	PART         = "PART"
 	KICK         = "KICK"
+	TOPIC        = "TOPIC"
+	MODE         = "MODE"
+	NAMES        = "NAMES"
+	LIST         = "LIST"
+	WHO          = "WHO"
+	WHOIS        = "WHOIS"
+	WHOWAS       = "WHOWAS"
+	AWAY         = "AWAY"
+	OPER         = "OPER"
+	MOTD         = "MOTD"
+	USERHOST     = "USERHOST"
+	ISON         = "ISON"
+	SETNAME      = "SETNAME"
+	MONITOR      = "MONITOR"
 	PING         = "PING"
 	PONG         = "PONG"
 	QUIT         = "QUIT"
+	CAP          = "CAP"
+	CHGHOST      = "CHGHOST"
+	AUTHENTICATE = "AUTHENTICATE"
 	RAW          = "RAW"
+	LAG          = "LAG"
+
+	CHANNEL_UPDATED = "CHANNEL_UPDATED"
+	CHANNEL_MODE    = "CHANNEL_MODE"
+	USER_MODE       = "USER_MODE"
+	OP              = "OP"
+	DEOP            = "DEOP"
+	VOICE           = "VOICE"
+	DEVOICE         = "DEVOICE"
+	BAN             = "BAN"
+	UNBAN           = "UNBAN"
+	WHOIS_REPLY     = "WHOIS_REPLY"
+	NAMES_REPLY     = "NAMES_REPLY"
+	LIST_ENTRY      = "LIST_ENTRY"
+	TOPIC_REPLY     = "TOPIC_REPLY"
 )
 
+// defaultUserMode asks for +iw
+const defaultUserMode = "12"
+
 type Commands struct {
 	stream *stream.Stream
 	out    chan<- string
+
+	// lineLimits returns the maximum line length and the length of our prefix
+	// to account for when splitting messages
+	lineLimits func() (int, int)
+	splitWords bool
+	// userMode is the mode bitmask sent with USER
+	userMode string
+	// equalFold compares nicks and channel names following the server casemapping
+	equalFold func(string, string) bool
+	// self reports whether the nick is ours
+	self func(string) bool
+	// labeled reports whether commands can be sent with labeled-response
+	labeled func() bool
+	labels  uint64
+	// unlabeled serialises the queries whose replies don't tell which one
+	// they answer
+	unlabeled sync.Mutex
+	// done returns the channel closed once the connection ends
+	done func() <-chan struct{}
 }
 
 func NewCommands(stream *stream.Stream, out chan<- string) *Commands {
-	return &Commands{stream, out}
+	return &Commands{stream: stream, out: out, userMode: defaultUserMode, equalFold: strings.EqualFold}
 }
 
 func (c *Commands) Nick(ctx context.Context, nick string) error {
 	return c.Command(ctx, NICK, nick)
 }
 
+// User sends the ident and realname along with the mode bitmask of
+// Config.UserMode.
 func (c *Commands) User(ctx context.Context, ident string, name string) error {
-	return c.Command(ctx, USER, ident, "12 * :"+name)
+	return c.Command(ctx, USER, ident, c.userMode, "*", ":"+name)
+}
+
+func (c *Commands) Pass(ctx context.Context, password string) error {
+	return c.CommandParams(ctx, PASS, password)
+}
+
+// WebIRC passes the real host and IP of the user connected through the gateway,
+// it must be the first command sent on the connection.
+func (c *Commands) WebIRC(ctx context.Context, password string, gateway string, hostname string, ip string, options ...string) error {
+	params := []string{password, gateway, hostname, ip}
+	if len(options) > 0 {
+		params = append(params, strings.Join(options, " "))
+	}
+	return c.CommandParams(ctx, WEBIRC, params...)
 }
 
 func (c *Commands) Join(ctx context.Context, channel string, key ...string) error {
-	params := channel
+	params := []string{channel}
 	if len(key) > 0 {
-		params = fmt.Sprintf("%s %s", channel, key[0])
+		params = append(params, key[0])
 	}
-	_, err := c.CommandWait(ctx, JOIN, params)
+	_, err := c.CommandWait(ctx, JOIN, params...)
 	return err
 }
 
+// Privmsg sends the message, split into multiple lines when it is too long
+// or contains newlines.
 func (c *Commands) Privmsg(ctx context.Context, target string, msg string) error {
-	return c.Command(ctx, PRIVMSG, target, ":"+msg)
+	return c.message(ctx, nil, PRIVMSG, target, "", msg)
+}
+
+// Notice sends the notice, split the same way as Privmsg.
+func (c *Commands) Notice(ctx context.Context, target string, msg string) error {
+	return c.message(ctx, nil, NOTICE, target, "", msg)
+}
+
+// Action sends the text as a CTCP ACTION, like /me, split the same way as
+// Privmsg.
+func (c *Commands) Action(ctx context.Context, target string, text string) error {
+	return c.message(ctx, nil, PRIVMSG, target, CTCP_ACTION, text)
+}
+
+// CTCP sends the CTCP query, replies coming as CTCP_REPLY events.
+func (c *Commands) CTCP(ctx context.Context, target string, command string, params ...string) error {
+	ctcp := CTCPMessage{Command: strings.ToUpper(command), Params: strings.Join(params, " ")}
+	return c.Command(ctx, PRIVMSG, target, ":"+ctcp.String())
+}
+
+// CTCPReply answers a CTCP query with a NOTICE.
+func (c *Commands) CTCPReply(ctx context.Context, target string, command string, params ...string) error {
+	ctcp := CTCPMessage{Command: strings.ToUpper(command), Params: strings.Join(params, " ")}
+	return c.Command(ctx, NOTICE, target, ":"+ctcp.String())
+}
+
+// message sends the text split into lines, each wrapped into the CTCP command
+// and sent with the tags when given.
+func (c *Commands) message(ctx context.Context, tags Tags, cmd string, target string, ctcp string, msg string) error {
+	maxLen := c.maxMessageLength(cmd, target)
+	if ctcp != "" {
+		// "\x01<ctcp> <text>\x01"
+		maxLen -= len(ctcp) + 3
+		if maxLen < 1 {
+			maxLen = 1
+		}
+	}
+	lines := SplitMessage(msg, maxLen, c.splitWords)
+	if len(lines) <= 0 && ctcp != "" {
+		// A CTCP message is never empty, even without text
+		lines = []string{""}
+	}
+	for _, line := range lines {
+		if ctcp != "" {
+			line = CTCPMessage{Command: ctcp, Params: line}.String()
+		}
+		if err := c.TagCommand(ctx, tags, cmd, target, ":"+line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Part leaves the channel with an optional reason.
+func (c *Commands) Part(ctx context.Context, channel string, reason ...string) error {
+	return c.CommandParams(ctx, PART, append([]string{channel}, joinReason(reason)...)...)
+}
+
+// Topic queries the topic of the channel, or changes it when a topic is
+// given. An empty topic clears it.
+func (c *Commands) Topic(ctx context.Context, channel string, topic ...string) error {
+	if len(topic) <= 0 {
+		return c.CommandParams(ctx, TOPIC, channel)
+	}
+	return c.CommandParams(ctx, TOPIC, channel, strings.Join(topic, " "))
+}
+
+func (c *Commands) Kick(ctx context.Context, channel string, nick string, reason ...string) error {
+	return c.CommandParams(ctx, KICK, append([]string{channel, nick}, joinReason(reason)...)...)
+}
+
+func (c *Commands) Invite(ctx context.Context, nick string, channel string) error {
+	return c.CommandParams(ctx, INVITE, nick, channel)
+}
+
+func (c *Commands) Names(ctx context.Context, channels ...string) error {
+	return c.CommandParams(ctx, NAMES, joinTargets(channels)...)
+}
+
+func (c *Commands) List(ctx context.Context, channels ...string) error {
+	return c.CommandParams(ctx, LIST, joinTargets(channels)...)
+}
+
+func (c *Commands) Who(ctx context.Context, mask string) error {
+	return c.CommandParams(ctx, WHO, mask)
+}
+
+func (c *Commands) Whois(ctx context.Context, nicks ...string) error {
+	return c.CommandParams(ctx, WHOIS, joinTargets(nicks)...)
+}
+
+// Whowas queries the nick history, limited to count entries when given.
+func (c *Commands) Whowas(ctx context.Context, nick string, count ...int) error {
+	if len(count) > 0 {
+		return c.CommandParams(ctx, WHOWAS, nick, strconv.Itoa(count[0]))
+	}
+	return c.CommandParams(ctx, WHOWAS, nick)
+}
+
+// Away marks us as away with the message, or as back when the message is empty.
+func (c *Commands) Away(ctx context.Context, message string) error {
+	if message == "" {
+		return c.CommandParams(ctx, AWAY)
+	}
+	return c.CommandParams(ctx, AWAY, message)
+}
+
+func (c *Commands) Oper(ctx context.Context, name string, password string) error {
+	return c.CommandParams(ctx, OPER, name, password)
+}
+
+// Motd requests the message of the day of our server, or of the given one.
+func (c *Commands) Motd(ctx context.Context, server ...string) error {
+	return c.CommandParams(ctx, MOTD, server...)
+}
+
+func (c *Commands) Userhost(ctx context.Context, nicks ...string) error {
+	return c.CommandParams(ctx, USERHOST, nicks...)
+}
+
+func (c *Commands) Ison(ctx context.Context, nicks ...string) error {
+	return c.CommandParams(ctx, ISON, nicks...)
+}
+
+// Setname changes our realname, which needs the setname capability.
+func (c *Commands) Setname(ctx context.Context, realname string) error {
+	return c.CommandParams(ctx, SETNAME, realname)
 }
 
 func (c *Commands) Ping(ctx context.Context, dst string) error {
 	return c.Command(ctx, PING, dst)
 }
 
-func (c *Commands) Pong(ctx context.Context, src string) error {
-	return c.Command(ctx, PONG, src)
+func (c *Commands) Pong(ctx context.Context, token string) error {
+	return c.Command(ctx, PONG, ":"+token)
 }
 
 func (c *Commands) Quit(ctx context.Context, messages ...string) error {
@@ -69,37 +273,87 @@ func (c *Commands) Quit(ctx context.Context, messages ...string) error {
 	return err
 }
 
-func (c *Commands) CommandWait(ctx context.Context, cmd string, args ...string) (Message, error) {
-	ch, id := c.stream.Observe(cmd)
-	defer c.stream.Remove(cmd, id)
-	if err := c.Command(ctx, cmd, args...); err != nil {
-		return Message{}, err
+// Command sends the command with the args joined by spaces, a last arg
+// starting with a colon being the trailing parameter.
+func (c *Commands) Command(ctx context.Context, cmd string, args ...string) error {
+	return c.TagCommand(ctx, nil, cmd, args...)
+}
+
+// CommandParams sends the command with each argument as a parameter, the last
+// one being sent as trailing parameter when needed.
+func (c *Commands) CommandParams(ctx context.Context, cmd string, params ...string) error {
+	return c.Send(ctx, Message{Cmd: cmd, Args: params})
+}
+
+// Send encodes and sends the message, failing when it can't be represented on
+// the wire.
+func (c *Commands) Send(ctx context.Context, msg Message) error {
+	line, err := msg.Encode()
+	if err != nil {
+		return err
 	}
-	select {
-	case item := <-ch:
-		if item.E != nil {
-			return Message{}, item.E
+	return c.Write(ctx, line)
+}
+
+// TagCommand sends the command with the tags like Command, failing when the
+// message can't be represented on the wire.
+func (c *Commands) TagCommand(ctx context.Context, tags Tags, cmd string, args ...string) error {
+	msg := Message{Tags: tags, Cmd: cmd}
+	for i, arg := range args {
+		if i == len(args)-1 && strings.HasPrefix(arg, ":") {
+			msg.Args = append(msg.Args, arg[1:])
+			msg.trailing = true
+			break
 		}
-		if message, ok := item.V.(Message); ok {
-			return message, nil
+		// An arg may hold several parameters, e.g. the modes
+		for _, param := range strings.Split(arg, " ") {
+			if param != "" {
+				msg.Args = append(msg.Args, param)
+			}
 		}
-		return Message{}, nil
-	case <-ctx.Done():
-		return Message{}, ctx.Err()
 	}
+	return c.Send(ctx, msg)
 }
 
-func (c *Commands) Command(ctx context.Context, cmd string, args ...string) error {
-	line := strings.Join(append([]string{cmd}, args...), " ")
-	return c.Write(ctx, line)
-}
-
+// Write sends the raw line, which must not contain any line break nor NUL.
 func (c *Commands) Write(ctx context.Context, line string) error {
-	parts := strings.SplitN(line, "\r\n", 2)
+	if strings.ContainsAny(line, forbiddenChars) {
+		return fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
 	select {
-	case c.out <- parts[0] + "\r\n":
+	case c.out <- line + "\r\n":
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 }
+
+// trailingParams prefixes the last parameter with a colon when it is empty,
+// contains spaces or starts with a colon itself.
+func trailingParams(params []string) []string {
+	n := len(params)
+	if n <= 0 {
+		return params
+	}
+	last := params[n-1]
+	if last != "" && !strings.HasPrefix(last, ":") && !strings.Contains(last, " ") {
+		return params
+	}
+	result := append([]string{}, params...)
+	result[n-1] = ":" + last
+	return result
+}
+
+func joinTargets(targets []string) []string {
+	if len(targets) <= 0 {
+		return nil
+	}
+	return []string{strings.Join(targets, ",")}
+}
+
+func joinReason(reason []string) []string {
+	if len(reason) <= 0 {
+		return nil
+	}
+	return []string{strings.Join(reason, " ")}
+}
@@ -0,0 +1,307 @@
+package irc
+
+import (
+	utesting "chatto/util/testing"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrailingParams(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"#chatto", "bye"}, trailingParams([]string{"#chatto", "bye"}))
+	assert.Equal([]string{"#chatto", ":good bye"}, trailingParams([]string{"#chatto", "good bye"}))
+	assert.Equal([]string{"#chatto", ":"}, trailingParams([]string{"#chatto", ""}))
+	assert.Equal([]string{"#chatto", "::)"}, trailingParams([]string{"#chatto", ":)"}))
+	assert.Len(trailingParams(nil), 0)
+}
+
+func connectTestClient(require *require.Assertions) (*Client, *testServer) {
+	srv, conn := newTestServer(require)
+	// The flood control would only slow down the tests
+	c := NewClient(Config{Nick: "chatto", Flood: FloodConfig{Burst: -1}})
+	err := connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+	return c, srv
+}
+
+func TestCommands(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	ctx := context.Background()
+	tests := []struct {
+		send     func() error
+		expected string
+	}{
+		{func() error { return c.Part(ctx, "#chatto") }, "PART #chatto"},
+		{func() error { return c.Part(ctx, "#chatto", "See you") }, "PART #chatto :See you"},
+		{func() error { return c.Notice(ctx, "alice", "Hi") }, "NOTICE alice :Hi"},
+		{func() error { return c.Topic(ctx, "#chatto") }, "TOPIC #chatto"},
+		{func() error { return c.Topic(ctx, "#chatto", "") }, "TOPIC #chatto :"},
+		{func() error { return c.Topic(ctx, "#chatto", "Welcome to Chatto") }, "TOPIC #chatto :Welcome to Chatto"},
+		{func() error { return c.Kick(ctx, "#chatto", "bob", "Out") }, "KICK #chatto bob Out"},
+		{func() error { return c.Invite(ctx, "alice", "#chatto") }, "INVITE alice #chatto"},
+		{func() error { return c.Names(ctx, "#chatto", "#go") }, "NAMES #chatto,#go"},
+		{func() error { return c.List(ctx) }, "LIST"},
+		{func() error { return c.Who(ctx, "#chatto") }, "WHO #chatto"},
+		{func() error { return c.Whois(ctx, "alice", "bob") }, "WHOIS alice,bob"},
+		{func() error { return c.Whowas(ctx, "alice", 2) }, "WHOWAS alice 2"},
+		{func() error { return c.Away(ctx, "Gone fishing") }, "AWAY :Gone fishing"},
+		{func() error { return c.Away(ctx, "") }, "AWAY"},
+		{func() error { return c.Oper(ctx, "admin", "secret") }, "OPER admin secret"},
+		{func() error { return c.Mode(ctx, "#chatto", "+k", "key") }, "MODE #chatto +k key"},
+		{func() error { return c.Motd(ctx) }, "MOTD"},
+		{func() error { return c.Userhost(ctx, "alice", "bob") }, "USERHOST alice bob"},
+		{func() error { return c.Ison(ctx, "alice", "bob") }, "ISON alice bob"},
+		{func() error { return c.Setname(ctx, "Chatto Bot") }, "SETNAME :Chatto Bot"},
+		{func() error { return c.User(ctx, "chatto", "Chatto Bot") }, "USER chatto 12 * :Chatto Bot"},
+	}
+	for _, test := range tests {
+		errs := make(chan error, 1)
+		go func(send func() error) {
+			errs <- send()
+		}(test.send)
+		srv.expect(test.expected)
+		require.Nil(<-errs)
+	}
+
+	require.Nil(closeClient(c, srv))
+}
+
+func TestInvalidCommands(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	// Nothing is sent when the line can't be represented on the wire
+	ctx := context.Background()
+	require.True(errors.Is(c.Command(ctx, PRIVMSG, "#chatto", ":Hi\r\nQUIT"), ErrInvalidParam))
+	require.True(errors.Is(c.Command(ctx, PRIVMSG, "#chatto\n", ":Hi"), ErrInvalidParam))
+	require.True(errors.Is(c.Command(ctx, "PRIV MSG", "#chatto", ":Hi"), ErrInvalidCommand))
+	require.True(errors.Is(c.TagCommand(ctx, Tags{"bad key": ""}, TAGMSG, "#chatto"), ErrInvalidTag))
+	require.True(errors.Is(c.TagCommand(ctx, Tags{"+draft/reply": "\x00"}, TAGMSG, "#chatto"), ErrInvalidTag))
+	require.True(errors.Is(c.Write(ctx, "PING a\r\nQUIT"), ErrInvalidLine))
+	require.True(errors.Is(c.Write(ctx, "PING a\x00"), ErrInvalidLine))
+	_, err := srv.session.Next(50 * time.Millisecond)
+	require.Equal(utesting.ErrIRCTimeout, err)
+
+	// The tag values are escaped
+	require.Nil(c.TagCommand(ctx, Tags{"+draft/reply": "a b;c"}, TAGMSG, "#chatto"))
+	srv.expect("@+draft/reply=a\\sb\\:c TAGMSG #chatto")
+	require.Nil(closeClient(c, srv))
+}
+
+func TestQueries(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	ctx := context.Background()
+	{
+		ch := make(chan []string, 1)
+		go func() {
+			names, err := c.NamesWait(ctx, "#chatto")
+			require.Nil(err)
+			ch <- names
+		}()
+		srv.expect("NAMES #chatto")
+		srv.send(
+			":irc.test 353 chatto = #go :gopher",
+			":irc.test 366 chatto #go :End of /NAMES list.",
+			":irc.test 353 chatto = #Chatto :@chatto +alice",
+			":irc.test 353 chatto #chatto :bob",
+			":irc.test 366 chatto #chatto :End of /NAMES list.",
+		)
+		require.Equal([]string{"@chatto", "+alice", "bob"}, <-ch)
+	}
+	{
+		ch := make(chan WhoisReply, 1)
+		go func() {
+			whois, err := c.WhoisWait(ctx, "alice")
+			require.Nil(err)
+			ch <- whois
+		}()
+		srv.expect("WHOIS alice")
+		srv.send(
+			":irc.test 311 chatto alice ~alice alice.host * :Alice",
+			":irc.test 311 chatto bob ~bob bob.host * :Bob",
+			":irc.test 319 chatto alice :@#chatto",
+			":irc.test 318 chatto bob :End of /WHOIS list.",
+			":irc.test 318 chatto alice :End of /WHOIS list.",
+		)
+		whois := <-ch
+		require.Equal("~alice", whois.User)
+		require.Equal("Alice", whois.Realname)
+		require.Equal([]string{"@#chatto"}, whois.Channels)
+	}
+	{
+		ch := make(chan []string, 1)
+		go func() {
+			lines, err := c.MotdWait(ctx)
+			require.Nil(err)
+			ch <- lines
+		}()
+		srv.expect("MOTD")
+		srv.send(
+			":irc.test 375 chatto :- irc.test Message of the day -",
+			":irc.test 372 chatto :- Hello",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+		require.Equal([]string{"- Hello"}, <-ch)
+	}
+	{
+		ch := make(chan []string, 1)
+		go func() {
+			nicks, err := c.IsonWait(ctx, "alice", "bob")
+			require.Nil(err)
+			ch <- nicks
+		}()
+		srv.expect("ISON alice bob")
+		srv.send(":irc.test 303 chatto :alice")
+		require.Equal([]string{"alice"}, <-ch)
+	}
+	{
+		// The replies don't tell which ISON they answer, so they run one by one
+		first, second := make(chan []string, 1), make(chan []string, 1)
+		go func() {
+			nicks, err := c.IsonWait(ctx, "alice")
+			require.Nil(err)
+			first <- nicks
+		}()
+		srv.expect("ISON alice")
+		go func() {
+			nicks, err := c.IsonWait(ctx, "bob")
+			require.Nil(err)
+			second <- nicks
+		}()
+		_, err := srv.session.Next(50 * time.Millisecond)
+		require.Equal(utesting.ErrIRCTimeout, err, "Expected the second ISON to wait")
+		srv.send(":irc.test 303 chatto :alice")
+		require.Equal([]string{"alice"}, <-first)
+		srv.expect("ISON bob")
+		srv.send(":irc.test 303 chatto :")
+		require.Equal([]string{}, <-second)
+	}
+
+	// The queries fail when the server rejects their command
+	failures := []struct {
+		query func() error
+		sent  string
+		reply string
+		code  string
+	}{
+		{
+			query: func() error { _, err := c.MotdWait(ctx, "irc.other"); return err },
+			sent:  "MOTD irc.other",
+			reply: ":irc.test 402 chatto irc.other :No such server",
+			code:  ERR_NOSUCHSERVER,
+		},
+		{
+			query: func() error { _, err := c.WhoWait(ctx, ""); return err },
+			sent:  "WHO :",
+			reply: ":irc.test 461 chatto WHO :Not enough parameters",
+			code:  ERR_NEEDMOREPARAMS,
+		},
+		{
+			query: func() error { _, err := c.WhowasWait(ctx, "alice"); return err },
+			sent:  "WHOWAS alice",
+			reply: ":irc.test 421 chatto WHOWAS :Unknown command",
+			code:  ERR_UNKNOWNCOMMAND,
+		},
+		{
+			query: func() error { _, err := c.ListWait(ctx); return err },
+			sent:  "LIST",
+			reply: ":irc.test 263 chatto LIST :Server load is temporarily too heavy",
+			code:  RPL_TRYAGAIN,
+		},
+		{
+			query: func() error { _, err := c.NamesWait(ctx, "#chatto"); return err },
+			sent:  "NAMES #chatto",
+			reply: ":irc.test FAIL NAMES TEMPORARILY_UNAVAILABLE :Try again later",
+			code:  "TEMPORARILY_UNAVAILABLE",
+		},
+	}
+	for _, failure := range failures {
+		ch := make(chan error, 1)
+		go func(query func() error) {
+			ch <- query()
+		}(failure.query)
+		srv.expect(failure.sent)
+		// The other commands' errors are ignored
+		srv.send(":irc.test 421 chatto FOO :Unknown command", failure.reply)
+		var replyErr *ReplyError
+		require.True(errors.As(<-ch, &replyErr), failure.sent)
+		require.Equal(failure.code, replyErr.Code)
+	}
+
+	require.Nil(closeClient(c, srv))
+}
+
+func TestQuit(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	// Quitting without a message doesn't wait for the server to close the link
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(c.Quit(ctx))
+	srv.expect(QUIT)
+
+	// With a message it waits for the server to close it
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c.Quit(ctx, "Bye")
+	}()
+	srv.expect("QUIT :Bye")
+	// Another user quitting doesn't end the wait
+	srv.send(":alice!alice@irc.test QUIT :Bye")
+	select {
+	case err := <-ch:
+		require.FailNow("Expected Quit to wait for the server", "%v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	srv.send("ERROR :Closing Link: chatto (Quit: Bye)")
+	require.Nil(<-ch)
+}
+
+func TestCloseTimeout(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	disconnected := make(chan struct{})
+	c.Once(context.Background(), DISCONNECTED, func(e Event) {
+		close(disconnected)
+	})
+
+	// The server never closes the link, the connection is torn down anyway
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c.Close(ctx)
+	}()
+	srv.expect(QUIT)
+	srv.send(":alice!alice@irc.test QUIT :Bye")
+	require.True(errors.Is(<-ch, context.DeadlineExceeded))
+	require.False(c.Connected())
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		require.FailNow("Expected DISCONNECTED event before timeout")
+	}
+}
@@ -2,12 +2,15 @@ package irc
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"net"
 	"sync"
 )
 
 type Conn struct {
 	*Client
+	tls       *TLSConfig
 	conn      net.Conn
 	mu        sync.RWMutex
 	connected bool
@@ -16,6 +19,7 @@ type Conn struct {
 func NewConn(cfg Config) *Conn {
 	return &Conn{
 		Client:    NewClient(cfg),
+		tls:       cfg.TLS,
 		connected: false,
 	}
 }
@@ -30,13 +34,20 @@ func (c *Conn) Connect(ctx context.Context, addr string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.connected {
-		return ErrAlreadyConnected
+		if c.Client.Connected() {
+			return ErrAlreadyConnected
+		}
+		// The previous connection got lost, release it before reconnecting
+		c.conn.Close()
+		c.conn = nil
+		c.connected = false
 	}
-	conn, err := net.Dial("tcp", addr)
+	conn, err := c.dial(ctx, addr)
 	if err != nil {
 		return err
 	}
 	if err := c.Client.Connect(ctx, conn); err != nil {
+		conn.Close()
 		return err
 	}
 	c.conn = conn
@@ -50,14 +61,39 @@ func (c *Conn) Close(ctx context.Context) error {
 	if !c.connected {
 		return ErrNotConnected
 	}
-	if err := c.Client.Close(ctx); err != nil {
-		return err
+	// Close the socket even if the client fails to quit gracefully
+	err := c.Client.Close(ctx)
+	if errors.Is(err, ErrNotConnected) {
+		err = nil
 	}
-	if err := c.conn.Close(); err != nil {
-		return err
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
 	}
 	c.conn = nil
-	c.Client = nil
 	c.connected = false
-	return nil
+	return err
+}
+
+// TLS reports whether the current connection is encrypted.
+func (c *Conn) TLS() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.conn.(*tls.Conn)
+	return ok
+}
+
+func (c *Conn) dial(ctx context.Context, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	if c.tls == nil {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	cfg, err := c.tls.build(addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsDialer := &tls.Dialer{
+		NetDialer: dialer,
+		Config:    cfg,
+	}
+	return tlsDialer.DialContext(ctx, "tcp", addr)
 }
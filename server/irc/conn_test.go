@@ -4,20 +4,27 @@ import (
 	"chatto/util/env"
 	utesting "chatto/util/testing"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestConnection(t *testing.T) {
-	if !env.IsIntegrationTest() {
-		t.SkipNow()
-		return
-	}
-
 	require := require.New(t)
 	ctx, cancel := utesting.CreateTestingContext()
 	defer cancel()
 
+	// Run against a real ircd for the integration tests only
+	addr := "127.0.0.1:6667"
+	var srv *utesting.IRCServer
+	if !env.IsIntegrationTest() {
+		srv = utesting.NewIRCServer()
+		defer srv.Close()
+		var err error
+		addr, err = srv.Listen()
+		require.Nil(err)
+	}
+
 	c := NewConn(Config{
 		Nick: "chatto-test",
 		Name: "chatto-irc test client",
@@ -33,15 +40,30 @@ func TestConnection(t *testing.T) {
 	c.Each(ctx, QUIT, countHandler)
 	c.Each(ctx, DISCONNECTED, countHandler)
 
-	require.Nil(c.Connect(ctx, "127.0.0.1:6667"))
+	require.Nil(c.Connect(ctx, addr))
 	require.True(c.Connected())
 
 	channel := "#chatto-test"
 	require.Nil(c.Join(ctx, channel))
 	require.Nil(c.Privmsg(ctx, channel, "Hello, world!"))
+	if srv != nil {
+		// Another user of the fake server joins and leaves
+		alice := srv.AddUser("alice")
+		alice.Exec("JOIN " + channel)
+		alice.Exec("QUIT :Bye")
+		require.Eventually(func() bool {
+			return counter.Int() == 4
+		}, time.Second, 10*time.Millisecond)
+	}
 	require.Nil(c.Close(ctx))
 	require.False(c.Connected())
 
-	// Count the called registered events
-	require.Equal(4, counter.Int())
+	// Count the called registered events, along with the ones of alice
+	expected := 4
+	if srv != nil {
+		expected = 5
+	}
+	require.Eventually(func() bool {
+		return counter.Int() == expected
+	}, time.Second, 10*time.Millisecond)
 }
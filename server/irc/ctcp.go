@@ -0,0 +1,183 @@
+package irc
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events of the CTCP messages, which are notified as PRIVMSG or NOTICE as well
+const (
+	CTCP       = "CTCP"
+	CTCP_REPLY = "CTCP_REPLY"
+	ACTION     = "ACTION"
+)
+
+const (
+	CTCP_ACTION     = "ACTION"
+	CTCP_VERSION    = "VERSION"
+	CTCP_PING       = "PING"
+	CTCP_TIME       = "TIME"
+	CTCP_CLIENTINFO = "CLIENTINFO"
+)
+
+const (
+	ctcpDelim           = "\x01"
+	defaultCTCPVersion  = "Chatto IRC client"
+	defaultCTCPBurst    = 4
+	defaultCTCPInterval = 2 * time.Second
+)
+
+type CTCPConfig struct {
+	// Disabled turns off the automatic replies
+	Disabled bool
+	// Version is the reply to VERSION, defaults to "Chatto IRC client"
+	Version string
+	// Replies overrides the automatic replies by command, a nil function
+	// leaving the command unanswered
+	Replies map[string]CTCPReplyFunc
+	// Burst is the number of queries answered in a row, defaults to 4 and
+	// disables the limit when negative. The queries over it are left unanswered.
+	Burst int
+	// Interval is the time needed to answer one more query, defaults to 2s
+	Interval time.Duration
+}
+
+// CTCPReplyFunc returns the parameters replied to the CTCP query, or false to
+// leave it unanswered.
+type CTCPReplyFunc func(e Event) (string, bool)
+
+// CTCPMessage is a CTCP query or reply, like "\x01VERSION\x01".
+type CTCPMessage struct {
+	Command string
+	Params  string
+}
+
+func (m CTCPMessage) String() string {
+	if m.Params == "" {
+		return ctcpDelim + m.Command + ctcpDelim
+	}
+	return ctcpDelim + m.Command + " " + m.Params + ctcpDelim
+}
+
+// ParseCTCP decodes the text of a PRIVMSG or NOTICE when it is a CTCP
+// message, the final delimiter being optional.
+func ParseCTCP(text string) (CTCPMessage, bool) {
+	if !strings.HasPrefix(text, ctcpDelim) {
+		return CTCPMessage{}, false
+	}
+	text = strings.TrimSuffix(text[1:], ctcpDelim)
+	parts := strings.SplitN(text, " ", 2)
+	if parts[0] == "" {
+		return CTCPMessage{}, false
+	}
+	msg := CTCPMessage{Command: strings.ToUpper(parts[0])}
+	if len(parts) > 1 {
+		msg.Params = parts[1]
+	}
+	return msg, true
+}
+
+// ctcpEvent returns the event of the message when it is a CTCP message.
+func (c *Client) ctcpEvent(msg Message) (string, Event, bool) {
+	if (msg.Cmd != PRIVMSG && msg.Cmd != NOTICE) || len(msg.Args) < 2 {
+		return "", Event{}, false
+	}
+	ctcp, ok := ParseCTCP(msg.Args[len(msg.Args)-1])
+	if !ok {
+		return "", Event{}, false
+	}
+	event := Event{Message: msg, CTCP: ctcp}
+	if c.isupport.IsChannel(msg.Args[0]) {
+		event.Channel = msg.Args[0]
+	}
+	switch {
+	case msg.Cmd == NOTICE:
+		return CTCP_REPLY, event, true
+	case ctcp.Command == CTCP_ACTION:
+		return ACTION, event, true
+	}
+	return CTCP, event, true
+}
+
+// ctcpReplies returns the automatic replies by command.
+func ctcpReplies(cfg CTCPConfig) map[string]CTCPReplyFunc {
+	replies := make(map[string]CTCPReplyFunc)
+	if cfg.Disabled {
+		return replies
+	}
+	version := cfg.Version
+	if version == "" {
+		version = defaultCTCPVersion
+	}
+	replies[CTCP_VERSION] = func(Event) (string, bool) {
+		return version, true
+	}
+	replies[CTCP_PING] = func(e Event) (string, bool) {
+		return e.CTCP.Params, true
+	}
+	replies[CTCP_TIME] = func(Event) (string, bool) {
+		return time.Now().Format(time.RFC1123Z), true
+	}
+	clientInfo := true
+	for command, reply := range cfg.Replies {
+		command = strings.ToUpper(command)
+		if command == CTCP_CLIENTINFO {
+			clientInfo = false
+		}
+		if reply == nil {
+			delete(replies, command)
+		} else {
+			replies[command] = reply
+		}
+	}
+	if !clientInfo {
+		return replies
+	}
+	// Lists the commands we understand, including itself
+	commands := []string{CTCP_ACTION, CTCP_CLIENTINFO}
+	for command := range replies {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	info := strings.Join(commands, " ")
+	replies[CTCP_CLIENTINFO] = func(Event) (string, bool) {
+		return info, true
+	}
+	return replies
+}
+
+// ctcpLimiter rate limits the automatic replies with a token bucket, so that
+// a flood of queries can't get us throttled or disconnected by the server.
+type ctcpLimiter struct {
+	mu       sync.Mutex
+	cfg      CTCPConfig
+	tokens   float64
+	refilled time.Time
+}
+
+func newCTCPLimiter(cfg CTCPConfig) *ctcpLimiter {
+	return &ctcpLimiter{cfg: cfg, tokens: float64(cfg.Burst), refilled: time.Now()}
+}
+
+// allow reports whether another reply can be sent, using up one token.
+func (l *ctcpLimiter) allow(now time.Time) bool {
+	if l.cfg.Burst < 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elapsed := now.Sub(l.refilled); elapsed > 0 {
+		l.refilled = now
+		l.tokens += float64(elapsed) / float64(l.cfg.Interval)
+		if max := float64(l.cfg.Burst); l.tokens > max {
+			l.tokens = max
+		}
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,214 @@
+package irc
+
+import (
+	utesting "chatto/util/testing"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCTCP(t *testing.T) {
+	assert := assert.New(t)
+	ctcp, ok := ParseCTCP("\x01ACTION waves\x01")
+	assert.True(ok)
+	assert.Equal(CTCPMessage{Command: CTCP_ACTION, Params: "waves"}, ctcp)
+	assert.Equal("\x01ACTION waves\x01", ctcp.String())
+
+	ctcp, ok = ParseCTCP("\x01version")
+	assert.True(ok)
+	assert.Equal(CTCPMessage{Command: CTCP_VERSION}, ctcp)
+	assert.Equal("\x01VERSION\x01", ctcp.String())
+
+	_, ok = ParseCTCP("Hello, world!")
+	assert.False(ok)
+	_, ok = ParseCTCP("\x01\x01")
+	assert.False(ok)
+}
+
+func TestCTCPReplies(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{
+		Nick:  "chatto",
+		Flood: FloodConfig{Burst: -1},
+		CTCP: CTCPConfig{
+			Version: "Chatto 1.0",
+			Replies: map[string]CTCPReplyFunc{
+				"time": nil,
+				"SOURCE": func(Event) (string, bool) {
+					return "https://github.com/Frizz925/chatto", true
+				},
+			},
+		},
+	})
+	require.Nil(connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	}))
+
+	ctx := context.Background()
+	actions := make(chan Event, 1)
+	messages := make(chan Event, 8)
+	c.Each(ctx, ACTION, func(e Event) {
+		actions <- e
+	})
+	c.Each(ctx, PRIVMSG, func(e Event) {
+		messages <- e
+	})
+
+	srv.send(":alice!alice@host PRIVMSG chatto :\x01VERSION\x01")
+	srv.expect("NOTICE alice :\x01VERSION Chatto 1.0\x01")
+	srv.send(":alice!alice@host PRIVMSG #chatto :\x01PING 1600000000\x01")
+	srv.expect("NOTICE alice :\x01PING 1600000000\x01")
+	srv.send(":alice!alice@host PRIVMSG chatto :\x01CLIENTINFO\x01")
+	srv.expect("NOTICE alice :\x01CLIENTINFO ACTION CLIENTINFO PING SOURCE VERSION\x01")
+	// Disabled and unknown queries are left unanswered
+	srv.send(
+		":alice!alice@host PRIVMSG chatto :\x01TIME\x01",
+		":alice!alice@host PRIVMSG chatto :\x01FINGER\x01",
+		":alice!alice@host PRIVMSG chatto :\x01SOURCE\x01",
+	)
+	srv.expect("NOTICE alice :\x01SOURCE https://github.com/Frizz925/chatto\x01")
+
+	// Actions are notified as messages as well
+	srv.send(
+		":alice!alice@host PRIVMSG #chatto :\x01ACTION waves\x01",
+		":alice!alice@host PRIVMSG #chatto :Hi",
+	)
+	action := <-actions
+	require.Equal("#chatto", action.Channel)
+	require.Equal(CTCPMessage{Command: CTCP_ACTION, Params: "waves"}, action.CTCP)
+	texts := make([]string, 0, 8)
+	for len(texts) < cap(texts) {
+		texts = append(texts, (<-messages).Message.Args[1])
+	}
+	require.Equal([]string{
+		"\x01VERSION\x01", "\x01PING 1600000000\x01", "\x01CLIENTINFO\x01",
+		"\x01TIME\x01", "\x01FINGER\x01", "\x01SOURCE\x01",
+		"\x01ACTION waves\x01", "Hi",
+	}, texts)
+
+	require.Nil(closeClient(c, srv))
+}
+
+func TestCTCPDisabled(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto", Flood: FloodConfig{Burst: -1}, CTCP: CTCPConfig{Disabled: true}})
+	require.Nil(connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	}))
+
+	// The queries are still notified
+	queries := make(chan Event, 1)
+	c.Each(context.Background(), CTCP, func(e Event) {
+		queries <- e
+	})
+	srv.send(":alice!alice@host PRIVMSG chatto :\x01VERSION\x01")
+	require.Equal(CTCP_VERSION, (<-queries).CTCP.Command)
+	require.Nil(closeClient(c, srv))
+}
+
+func TestCTCPRateLimit(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto", Flood: FloodConfig{Burst: -1}, CTCP: CTCPConfig{Burst: 2, Interval: time.Hour}})
+	require.Nil(connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	}))
+
+	// The queries over the burst are left unanswered
+	srv.send(
+		":alice!alice@host PRIVMSG chatto :\x01PING 1\x01",
+		":bob!bob@host PRIVMSG chatto :\x01PING 2\x01",
+		":alice!alice@host PRIVMSG chatto :\x01PING 3\x01",
+	)
+	srv.expect("NOTICE alice :\x01PING 1\x01")
+	srv.expect("NOTICE bob :\x01PING 2\x01")
+	_, err := srv.session.Next(50 * time.Millisecond)
+	require.Equal(utesting.ErrIRCTimeout, err)
+	require.Nil(closeClient(c, srv))
+}
+
+func TestCTCPCommands(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	ctx := context.Background()
+	replies := make(chan Event, 1)
+	c.Each(ctx, CTCP_REPLY, func(e Event) {
+		replies <- e
+	})
+
+	tests := []struct {
+		send     func() error
+		expected []string
+	}{
+		{func() error { return c.Action(ctx, "#chatto", "waves") }, []string{"PRIVMSG #chatto :\x01ACTION waves\x01"}},
+		{func() error { return c.Action(ctx, "#chatto", "waves\nand leaves") }, []string{
+			"PRIVMSG #chatto :\x01ACTION waves\x01",
+			"PRIVMSG #chatto :\x01ACTION and leaves\x01",
+		}},
+		{func() error { return c.CTCP(ctx, "alice", "version") }, []string{"PRIVMSG alice :\x01VERSION\x01"}},
+		{func() error { return c.CTCPReply(ctx, "alice", CTCP_PING, "123") }, []string{"NOTICE alice :\x01PING 123\x01"}},
+	}
+	for _, test := range tests {
+		errs := make(chan error, 1)
+		go func(send func() error) {
+			errs <- send()
+		}(test.send)
+		for _, expected := range test.expected {
+			srv.expect(expected)
+		}
+		require.Nil(<-errs)
+	}
+
+	// Long actions are split so that each line keeps its delimiters
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Action(ctx, "#chatto", strings.Repeat("a", 600))
+	}()
+	text := ""
+	for len(text) < 600 {
+		line := srv.readLine()
+		require.True(len(line) <= maxLineLength-2)
+		ctcp, ok := ParseCTCP(strings.TrimPrefix(line, "PRIVMSG #chatto :"))
+		require.True(ok)
+		require.True(strings.HasSuffix(line, "\x01"))
+		text += ctcp.Params
+	}
+	require.Nil(<-errs)
+	require.Equal(strings.Repeat("a", 600), text)
+
+	srv.send(":alice!alice@host NOTICE chatto :\x01VERSION Alice 2.0\x01")
+	reply := <-replies
+	require.Equal(CTCPMessage{Command: CTCP_VERSION, Params: "Alice 2.0"}, reply.CTCP)
+	require.Equal("alice", reply.Message.Nick)
+
+	require.Nil(closeClient(c, srv))
+}
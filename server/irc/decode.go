@@ -0,0 +1,231 @@
+package irc
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WhoisReply gathers the replies to a WHOIS up to RPL_ENDOFWHOIS.
+type WhoisReply struct {
+	Nick     string
+	User     string
+	Host     string
+	Realname string
+	// Server is the server the user is connected to
+	Server     string
+	ServerInfo string
+	// Account is set when the user is logged in
+	Account string
+	// Away holds the away message when the user is away
+	Away string
+	// Channels lists the channels along with the membership prefixes of the user
+	Channels []string
+	Idle     time.Duration
+	SignOn   time.Time
+	Operator bool
+	Secure   bool
+}
+
+// NamesReply gathers the RPL_NAMREPLY of a channel up to RPL_ENDOFNAMES.
+type NamesReply struct {
+	Channel string
+	Members []Member
+}
+
+// ListEntry is a channel of a RPL_LIST reply.
+type ListEntry struct {
+	Channel string
+	Users   int
+	Topic   string
+}
+
+// TopicReply is a RPL_TOPIC along with its RPL_TOPICWHOTIME when sent.
+type TopicReply struct {
+	Channel string
+	Topic
+}
+
+// ParseWhois decodes the replies of a WHOIS about a single nick.
+func ParseWhois(replies []Message) WhoisReply {
+	whois := WhoisReply{}
+	for _, msg := range replies {
+		args := msg.Args
+		if len(args) < 2 {
+			continue
+		}
+		whois.Nick = args[1]
+		last := args[len(args)-1]
+		switch msg.Cmd {
+		case RPL_WHOISUSER:
+			if len(args) >= 6 {
+				whois.User, whois.Host, whois.Realname = args[2], args[3], last
+			}
+		case RPL_WHOISSERVER:
+			if len(args) >= 4 {
+				whois.Server, whois.ServerInfo = args[2], last
+			}
+		case RPL_WHOISOPERATOR:
+			whois.Operator = true
+		case RPL_WHOISIDLE:
+			if len(args) >= 4 {
+				if secs, err := strconv.ParseInt(args[2], 10, 64); err == nil {
+					whois.Idle = time.Duration(secs) * time.Second
+				}
+			}
+			if len(args) >= 5 {
+				if ts, err := strconv.ParseInt(args[3], 10, 64); err == nil {
+					whois.SignOn = time.Unix(ts, 0)
+				}
+			}
+		case RPL_WHOISCHANNELS:
+			whois.Channels = append(whois.Channels, strings.Fields(last)...)
+		case RPL_WHOISACCOUNT:
+			if len(args) >= 4 {
+				whois.Account = args[2]
+			}
+		case RPL_AWAY:
+			whois.Away = last
+		case RPL_WHOISSECURE:
+			whois.Secure = true
+		}
+	}
+	return whois
+}
+
+// ParseNames decodes the RPL_NAMREPLY replies of a channel.
+func ParseNames(isupport *ISupport, replies []Message) NamesReply {
+	names := NamesReply{Members: make([]Member, 0)}
+	for _, msg := range replies {
+		// "<client> <symbol> <channel> :<names>", the symbol is missing on some servers
+		if msg.Cmd != RPL_NAMREPLY || len(msg.Args) < 3 {
+			continue
+		}
+		names.Channel = msg.Args[len(msg.Args)-2]
+		names.Members = append(names.Members, parseNames(isupport, msg.Args[len(msg.Args)-1])...)
+	}
+	return names
+}
+
+// ParseListEntry decodes a RPL_LIST reply.
+func ParseListEntry(msg Message) (ListEntry, bool) {
+	if msg.Cmd != RPL_LIST || len(msg.Args) < 3 {
+		return ListEntry{}, false
+	}
+	entry := ListEntry{Channel: msg.Args[1]}
+	entry.Users, _ = strconv.Atoi(msg.Args[2])
+	if len(msg.Args) > 3 {
+		entry.Topic = msg.Args[len(msg.Args)-1]
+	}
+	return entry, true
+}
+
+// ParseTopic decodes a RPL_TOPIC or RPL_NOTOPIC, followed by an optional
+// RPL_TOPICWHOTIME.
+func ParseTopic(replies []Message) TopicReply {
+	topic := TopicReply{}
+	for _, msg := range replies {
+		args := msg.Args
+		if len(args) < 2 {
+			continue
+		}
+		topic.Channel = args[1]
+		switch msg.Cmd {
+		case RPL_TOPIC:
+			if len(args) >= 3 {
+				topic.Text = args[len(args)-1]
+			}
+		case RPL_TOPICWHOTIME:
+			if len(args) >= 4 {
+				topic.SetBy = args[2]
+				if ts, err := strconv.ParseInt(args[3], 10, 64); err == nil {
+					topic.SetAt = time.Unix(ts, 0)
+				}
+			}
+		}
+	}
+	return topic
+}
+
+// replyDecoder gathers the numeric replies spanning several messages and
+// emits their decoded form once complete.
+type replyDecoder struct {
+	mu    sync.Mutex
+	whois map[string][]Message
+	names map[string][]Message
+	topic []Message
+}
+
+func newReplyDecoder() *replyDecoder {
+	d := &replyDecoder{}
+	d.reset()
+	return d
+}
+
+func (d *replyDecoder) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.whois = make(map[string][]Message)
+	d.names = make(map[string][]Message)
+	d.topic = nil
+}
+
+// handle is called in order with every received message.
+func (d *replyDecoder) handle(c *Client, msg Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// RPL_TOPICWHOTIME is optional, so the topic is complete with any other message
+	if len(d.topic) > 0 && msg.Cmd != RPL_TOPICWHOTIME {
+		d.emitTopic(c)
+	}
+	if len(msg.Args) < 2 {
+		return
+	}
+	switch msg.Cmd {
+	case RPL_WHOISUSER, RPL_WHOISSERVER, RPL_WHOISOPERATOR, RPL_WHOISIDLE, RPL_WHOISCHANNELS,
+		RPL_WHOISACCOUNT, RPL_WHOISSECURE, RPL_AWAY:
+		nick := c.isupport.Fold(msg.Args[1])
+		if msg.Cmd == RPL_AWAY && len(d.whois[nick]) <= 0 {
+			// Sent when messaging an away user as well
+			return
+		}
+		d.whois[nick] = append(d.whois[nick], msg)
+	case RPL_ENDOFWHOIS:
+		nick := c.isupport.Fold(msg.Args[1])
+		if replies, ok := d.whois[nick]; ok {
+			delete(d.whois, nick)
+			c.notifyEvent(WHOIS_REPLY, Event{Message: msg, Decoded: ParseWhois(replies)})
+		}
+	case RPL_NAMREPLY:
+		if len(msg.Args) > 2 {
+			channel := c.isupport.Fold(msg.Args[len(msg.Args)-2])
+			d.names[channel] = append(d.names[channel], msg)
+		}
+	case RPL_ENDOFNAMES:
+		channel := c.isupport.Fold(msg.Args[1])
+		replies := d.names[channel]
+		delete(d.names, channel)
+		names := ParseNames(c.isupport, replies)
+		names.Channel = msg.Args[1]
+		c.notifyEvent(NAMES_REPLY, Event{Message: msg, Channel: msg.Args[1], Decoded: names})
+	case RPL_LIST:
+		if entry, ok := ParseListEntry(msg); ok {
+			c.notifyEvent(LIST_ENTRY, Event{Message: msg, Channel: entry.Channel, Decoded: entry})
+		}
+	case RPL_TOPIC, RPL_NOTOPIC:
+		d.topic = []Message{msg}
+	case RPL_TOPICWHOTIME:
+		if len(d.topic) > 0 {
+			d.topic = append(d.topic, msg)
+			d.emitTopic(c)
+		}
+	}
+}
+
+func (d *replyDecoder) emitTopic(c *Client) {
+	msg := d.topic[len(d.topic)-1]
+	topic := ParseTopic(d.topic)
+	d.topic = nil
+	c.notifyEvent(TOPIC_REPLY, Event{Message: msg, Channel: topic.Channel, Decoded: topic})
+}
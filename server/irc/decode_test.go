@@ -0,0 +1,98 @@
+package irc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReplies(t *testing.T) {
+	require := require.New(t)
+
+	whois := ParseWhois([]Message{
+		parseLine(":irc.test 311 chatto alice ~alice alice.host * :Alice"),
+		parseLine(":irc.test 312 chatto alice irc.test :Test server"),
+		parseLine(":irc.test 317 chatto alice 42 1600000000 :seconds idle, signon time"),
+		parseLine(":irc.test 319 chatto alice :@#chatto +#go"),
+		parseLine(":irc.test 330 chatto alice alice_acc :is logged in as"),
+		parseLine(":irc.test 671 chatto alice :is using a secure connection"),
+	})
+	require.Equal("alice", whois.Nick)
+	require.Equal("~alice", whois.User)
+	require.Equal("alice.host", whois.Host)
+	require.Equal("Alice", whois.Realname)
+	require.Equal("irc.test", whois.Server)
+	require.Equal(42*time.Second, whois.Idle)
+	require.Equal(int64(1600000000), whois.SignOn.Unix())
+	require.Equal([]string{"@#chatto", "+#go"}, whois.Channels)
+	require.Equal("alice_acc", whois.Account)
+	require.True(whois.Secure)
+	require.False(whois.Operator)
+
+	entry, ok := ParseListEntry(parseLine(":irc.test 322 chatto #chatto 12 :Chatto channel"))
+	require.True(ok)
+	require.Equal(ListEntry{Channel: "#chatto", Users: 12, Topic: "Chatto channel"}, entry)
+	_, ok = ParseListEntry(parseLine(":irc.test 323 chatto :End of /LIST"))
+	require.False(ok)
+
+	topic := ParseTopic([]Message{
+		parseLine(":irc.test 332 chatto #chatto :Welcome to chatto"),
+		parseLine(":irc.test 333 chatto #chatto alice 1600000000"),
+	})
+	require.Equal("#chatto", topic.Channel)
+	require.Equal("Welcome to chatto", topic.Text)
+	require.Equal("alice", topic.SetBy)
+	require.Equal(int64(1600000000), topic.SetAt.Unix())
+
+	require.Equal("RPL_WELCOME", NumericName(RPL_WELCOME))
+	require.Equal("ERR_NICKNAMEINUSE", NumericName(ERR_NICKNAMEINUSE))
+	require.Equal("999", NumericName("999"))
+}
+
+func TestReplyEvents(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	ctx := context.Background()
+	events := make(chan Event, 4)
+	for _, name := range []string{WHOIS_REPLY, TOPIC_REPLY, NAMES_REPLY, LIST_ENTRY} {
+		c.Once(ctx, name, func(e Event) {
+			events <- e
+		})
+	}
+	srv.send(
+		":irc.test 311 chatto alice ~alice alice.host * :Alice",
+		":irc.test 319 chatto alice :#chatto",
+		":irc.test 318 chatto alice :End of /WHOIS list.",
+	)
+	e := <-events
+	require.Equal("alice", e.Decoded.(WhoisReply).Nick)
+	require.Equal([]string{"#chatto"}, e.Decoded.(WhoisReply).Channels)
+
+	srv.send(
+		":irc.test 332 chatto #chatto :Welcome to chatto",
+		":irc.test 333 chatto #chatto alice 1600000000",
+	)
+	e = <-events
+	require.Equal("#chatto", e.Channel)
+	require.Equal("alice", e.Decoded.(TopicReply).SetBy)
+
+	srv.send(
+		":irc.test 353 chatto = #chatto :@alice +bob",
+		":irc.test 366 chatto #chatto :End of /NAMES list.",
+	)
+	e = <-events
+	names := e.Decoded.(NamesReply)
+	require.Equal("#chatto", names.Channel)
+	require.Len(names.Members, 2)
+	require.Equal("alice", names.Members[0].Nick)
+
+	srv.send(":irc.test 322 chatto #go 3 :Go channel")
+	e = <-events
+	require.Equal(ListEntry{Channel: "#go", Users: 3, Topic: "Go channel"}, e.Decoded)
+
+	require.Nil(closeClient(c, srv))
+}
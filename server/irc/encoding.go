@@ -0,0 +1,76 @@
+package irc
+
+import (
+	"chatto/irc/charset"
+	"strings"
+	"unicode/utf8"
+)
+
+type EncodingConfig struct {
+	// Charset decodes the received lines which aren't valid UTF-8 and encodes
+	// the sent ones, defaults to UTF-8
+	Charset *charset.Charset
+	// Fallback decodes the lines which aren't valid UTF-8 when the charset is
+	// UTF-8, defaults to latin-1
+	Fallback *charset.Charset
+	// Channels overrides the charset of the lines about the channels by name
+	Channels map[string]*charset.Charset
+}
+
+// decodeLine returns the line as UTF-8, decoding it with the charset of its
+// channel or connection when it isn't valid UTF-8 already.
+func (c *Client) decodeLine(line string) string {
+	if utf8.ValidString(line) {
+		return line
+	}
+	cs := c.lineCharset(parseLine(line))
+	if cs.IsUTF8() {
+		cs = c.cfg.Encoding.Fallback
+	}
+	return cs.Decode(line)
+}
+
+// encodeLine converts the payload to the charset of its channel or connection.
+func (c *Client) encodeLine(payload string) string {
+	cs := c.cfg.Encoding.Charset
+	if len(c.cfg.Encoding.Channels) > 0 {
+		cs = c.lineCharset(parseLine(strings.TrimRight(payload, "\r\n")))
+	}
+	return cs.Encode(payload)
+}
+
+// channelArgs holds the index of the channel parameter of the numerics about
+// a channel, the commands having it first.
+var channelArgs = map[string]int{
+	RPL_LIST:             1,
+	RPL_CHANNELMODEIS:    1,
+	RPL_CREATIONTIME:     1,
+	RPL_NOTOPIC:          1,
+	RPL_TOPIC:            1,
+	RPL_TOPICWHOTIME:     1,
+	RPL_NAMREPLY:         2,
+	RPL_ENDOFNAMES:       1,
+	RPL_BANLIST:          1,
+	RPL_ENDOFBANLIST:     1,
+	ERR_CANNOTSENDTOCHAN: 1,
+	ERR_CHANOPRIVSNEEDED: 1,
+}
+
+// lineCharset returns the charset configured for the channel the message is
+// sent to or about, or the one of the connection.
+func (c *Client) lineCharset(msg Message) *charset.Charset {
+	idx, ok := 0, true
+	if isNumeric(msg.Cmd) {
+		idx, ok = channelArgs[msg.Cmd]
+	}
+	if !ok || idx >= len(msg.Args) || !c.isupport.IsChannel(msg.Args[idx]) {
+		return c.cfg.Encoding.Charset
+	}
+	target := msg.Args[idx]
+	for channel, cs := range c.cfg.Encoding.Channels {
+		if cs != nil && c.isupport.EqualFold(channel, target) {
+			return cs
+		}
+	}
+	return c.cfg.Encoding.Charset
+}
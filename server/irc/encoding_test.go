@@ -0,0 +1,105 @@
+package irc
+
+import (
+	"chatto/irc/charset"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoding(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{
+		Nick:  "chatto",
+		Flood: FloodConfig{Burst: -1},
+		Encoding: EncodingConfig{
+			Channels: map[string]*charset.Charset{"#Russian": charset.CP1251},
+		},
+	})
+	require.Nil(connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	}))
+
+	ctx := context.Background()
+	messages := make(chan Message, 1)
+	c.Each(ctx, PRIVMSG, func(e Event) {
+		messages <- e.Message
+	})
+
+	// UTF-8 lines are kept as is, the others decoded with the fallback
+	srv.send(":alice!alice@host PRIVMSG #chatto :caf\xc3\xa9")
+	require.Equal("café", (<-messages).Args[1])
+	srv.send(":alice!alice@host PRIVMSG #chatto :caf\xe9")
+	require.Equal("café", (<-messages).Args[1])
+	// or with the charset of the channel
+	srv.send(":\xc1\xee\xf0\xe8\xf1!boris@host PRIVMSG #russian :\xcf\xf0\xe8\xe2\xe5\xf2")
+	msg := <-messages
+	require.Equal("Борис", msg.Nick)
+	require.Equal("Привет", msg.Args[1])
+	srv.send(":boris!boris@host PRIVMSG #russian :\xd0\x9f\xd1\x80\xd0\xb8\xd0\xb2\xd0\xb5\xd1\x82")
+	require.Equal("Привет", (<-messages).Args[1])
+	// Only the target of the message picks the charset, not its text
+	srv.send(":alice!alice@host PRIVMSG #chatto :#russian caf\xe9")
+	require.Equal("#russian café", (<-messages).Args[1])
+	srv.send(":jos\xe9!jose@host PRIVMSG chatto #russian")
+	require.Equal("josé", (<-messages).Nick)
+	names := make(chan Message, 1)
+	c.Each(ctx, RPL_NAMREPLY, func(e Event) {
+		names <- e.Message
+	})
+	srv.send(":irc.test 353 chatto = #russian :\xc1\xee\xf0\xe8\xf1")
+	require.Equal("Борис", (<-names).Args[3])
+
+	// Sent lines are encoded with the charset of their channel
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Privmsg(ctx, "#russian", "Привет, café")
+	}()
+	srv.expect("PRIVMSG #russian :\xcf\xf0\xe8\xe2\xe5\xf2, caf?")
+	require.Nil(<-errs)
+	go func() {
+		errs <- c.Privmsg(ctx, "#chatto", "Привет")
+	}()
+	srv.expect("PRIVMSG #chatto :Привет")
+	require.Nil(<-errs)
+
+	require.Nil(closeClient(c, srv))
+}
+
+func TestEncodingCharset(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{
+		Nick:     "chatto",
+		Name:     "Chatto Bötchen",
+		Flood:    FloodConfig{Burst: -1},
+		Encoding: EncodingConfig{Charset: charset.CP1252},
+	})
+	require.Nil(connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto B\xf6tchen")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	}))
+
+	topics := make(chan Message, 1)
+	c.Each(context.Background(), TOPIC, func(e Event) {
+		topics <- e.Message
+	})
+	srv.send(":alice!alice@host TOPIC #chatto :\x80 5")
+	require.Equal("€ 5", (<-topics).Args[1])
+	require.Nil(closeClient(c, srv))
+}
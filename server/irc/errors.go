@@ -1,5 +1,148 @@
 package irc
 
+import (
+	"errors"
+	"fmt"
+)
+
+// Error replies from RFC 1459, RFC 2812 and the modern IRC client protocol
 const (
-	ERR_NICKNAMEINUSE = "433"
+	ERR_UNKNOWNERROR      = "400"
+	ERR_NOSUCHNICK        = "401"
+	ERR_NOSUCHSERVER      = "402"
+	ERR_NOSUCHCHANNEL     = "403"
+	ERR_CANNOTSENDTOCHAN  = "404"
+	ERR_TOOMANYCHANNELS   = "405"
+	ERR_WASNOSUCHNICK     = "406"
+	ERR_TOOMANYTARGETS    = "407"
+	ERR_NOSUCHSERVICE     = "408"
+	ERR_NOORIGIN          = "409"
+	ERR_INVALIDCAPCMD     = "410"
+	ERR_NORECIPIENT       = "411"
+	ERR_NOTEXTTOSEND      = "412"
+	ERR_NOTOPLEVEL        = "413"
+	ERR_WILDTOPLEVEL      = "414"
+	ERR_BADMASK           = "415"
+	ERR_INPUTTOOLONG      = "417"
+	ERR_UNKNOWNCOMMAND    = "421"
+	ERR_NOMOTD            = "422"
+	ERR_NOADMININFO       = "423"
+	ERR_FILEERROR         = "424"
+	ERR_NONICKNAMEGIVEN   = "431"
+	ERR_ERRONEUSNICKNAME  = "432"
+	ERR_NICKNAMEINUSE     = "433"
+	ERR_NICKCOLLISION     = "436"
+	ERR_UNAVAILRESOURCE   = "437"
+	ERR_USERNOTINCHANNEL  = "441"
+	ERR_NOTONCHANNEL      = "442"
+	ERR_USERONCHANNEL     = "443"
+	ERR_NOLOGIN           = "444"
+	ERR_SUMMONDISABLED    = "445"
+	ERR_USERSDISABLED     = "446"
+	ERR_NOTREGISTERED     = "451"
+	ERR_NEEDMOREPARAMS    = "461"
+	ERR_ALREADYREGISTERED = "462"
+	ERR_NOPERMFORHOST     = "463"
+	ERR_PASSWDMISMATCH    = "464"
+	ERR_YOUREBANNEDCREEP  = "465"
+	ERR_YOUWILLBEBANNED   = "466"
+	ERR_KEYSET            = "467"
+	ERR_CHANNELISFULL     = "471"
+	ERR_UNKNOWNMODE       = "472"
+	ERR_INVITEONLYCHAN    = "473"
+	ERR_BANNEDFROMCHAN    = "474"
+	ERR_BADCHANNELKEY     = "475"
+	ERR_BADCHANMASK       = "476"
+	ERR_NEEDREGGEDNICK    = "477"
+	ERR_BANLISTFULL       = "478"
+	ERR_NOPRIVILEGES      = "481"
+	ERR_CHANOPRIVSNEEDED  = "482"
+	ERR_CANTKILLSERVER    = "483"
+	ERR_RESTRICTED        = "484"
+	ERR_UNIQOPPRIVSNEEDED = "485"
+	ERR_NOOPERHOST        = "491"
+	ERR_UMODEUNKNOWNFLAG  = "501"
+	ERR_USERSDONTMATCH    = "502"
+	ERR_HELPNOTFOUND      = "524"
+	ERR_INVALIDKEY        = "525"
+	ERR_STARTTLS          = "691"
+	ERR_INVALIDMODEPARAM  = "696"
+	ERR_NOPRIVS           = "723"
+	ERR_MONLISTFULL       = "734"
+	ERR_NICKLOCKED        = "902"
+	ERR_SASLFAIL          = "904"
+	ERR_SASLTOOLONG       = "905"
+	ERR_SASLABORTED       = "906"
+	ERR_SASLALREADY       = "907"
+)
+
+var (
+	ErrNoSuchNick       = errors.New("no such nick")
+	ErrNoSuchServer     = errors.New("no such server")
+	ErrNoSuchChannel    = errors.New("no such channel")
+	ErrTooManyChannels  = errors.New("too many channels")
+	ErrUnknownCommand   = errors.New("unknown command")
+	ErrErroneusNickname = errors.New("erroneous nickname")
+	ErrNicknameInUse    = errors.New("nickname is already in use")
+	ErrNickCollision    = errors.New("nickname collision")
+	ErrUnavailResource  = errors.New("nick or channel is temporarily unavailable")
+	ErrUserNotInChannel = errors.New("user is not on that channel")
+	ErrNotOnChannel     = errors.New("not on that channel")
+	ErrUserOnChannel    = errors.New("user is already on that channel")
+	ErrNeedMoreParams   = errors.New("not enough parameters")
+	ErrPasswdMismatch   = errors.New("password incorrect")
+	ErrChannelIsFull    = errors.New("channel is full")
+	ErrInviteOnlyChan   = errors.New("channel is invite only")
+	ErrBannedFromChan   = errors.New("banned from channel")
+	ErrBadChannelKey    = errors.New("bad channel key")
+	ErrBadChanMask      = errors.New("bad channel mask")
+	ErrNeedReggedNick   = errors.New("registered nick required")
+	ErrChanOPrivsNeeded = errors.New("channel operator privileges needed")
+	ErrNoOperHost       = errors.New("no operator block for host")
+	ErrTryAgain         = errors.New("server dropped the command, try again")
 )
+
+var replyErrors = map[string]error{
+	ERR_NOSUCHNICK:       ErrNoSuchNick,
+	ERR_NOSUCHSERVER:     ErrNoSuchServer,
+	ERR_NOSUCHCHANNEL:    ErrNoSuchChannel,
+	ERR_TOOMANYCHANNELS:  ErrTooManyChannels,
+	ERR_UNKNOWNCOMMAND:   ErrUnknownCommand,
+	ERR_ERRONEUSNICKNAME: ErrErroneusNickname,
+	ERR_NICKNAMEINUSE:    ErrNicknameInUse,
+	ERR_NICKCOLLISION:    ErrNickCollision,
+	ERR_UNAVAILRESOURCE:  ErrUnavailResource,
+	ERR_USERNOTINCHANNEL: ErrUserNotInChannel,
+	ERR_NOTONCHANNEL:     ErrNotOnChannel,
+	ERR_USERONCHANNEL:    ErrUserOnChannel,
+	ERR_NEEDMOREPARAMS:   ErrNeedMoreParams,
+	ERR_PASSWDMISMATCH:   ErrPasswdMismatch,
+	ERR_CHANNELISFULL:    ErrChannelIsFull,
+	ERR_INVITEONLYCHAN:   ErrInviteOnlyChan,
+	ERR_BANNEDFROMCHAN:   ErrBannedFromChan,
+	ERR_BADCHANNELKEY:    ErrBadChannelKey,
+	ERR_BADCHANMASK:      ErrBadChanMask,
+	ERR_NEEDREGGEDNICK:   ErrNeedReggedNick,
+	ERR_CHANOPRIVSNEEDED: ErrChanOPrivsNeeded,
+	ERR_NOOPERHOST:       ErrNoOperHost,
+	RPL_TRYAGAIN:         ErrTryAgain,
+}
+
+// ReplyError is returned when the server answers a command with an error
+// numeric or a FAIL standard reply. It unwraps to the matching ErrXxx error.
+type ReplyError struct {
+	// Code is the error numeric, or the code of a FAIL reply
+	Code    string
+	Command string
+	Message string
+	// Reply holds the whole reply
+	Reply Message
+}
+
+func (e *ReplyError) Error() string {
+	return fmt.Sprintf("%s failed: %s (%s)", e.Command, e.Message, NumericName(e.Code))
+}
+
+func (e *ReplyError) Unwrap() error {
+	return replyErrors[e.Code]
+}
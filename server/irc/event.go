@@ -1,11 +1,22 @@
 package irc
 
-import "chatto/util/stream"
+import (
+	"chatto/util/stream"
+	"context"
+)
 
 type Event struct {
 	Client  *Client
 	Message Message
 	Error   error
+	// Channel is set by the events about a channel state
+	Channel string
+	// Modes holds the changes of the mode events
+	Modes []ModeChange
+	// Decoded holds the decoded reply of the reply events, like a WhoisReply
+	Decoded interface{}
+	// CTCP holds the query or reply of the CTCP events
+	CTCP CTCPMessage
 }
 
 func eventFromStream(client *Client, item stream.Item) Event {
@@ -13,8 +24,192 @@ func eventFromStream(client *Client, item stream.Item) Event {
 		Client: client,
 		Error:  item.E,
 	}
-	if message, ok := item.V.(Message); ok {
-		event.Message = message
+	switch v := item.V.(type) {
+	case Message:
+		event.Message = v
+	case Event:
+		event = v
+		event.Client = client
+		if item.E != nil {
+			event.Error = item.E
+		}
 	}
 	return event
 }
+
+// User is the source of a message.
+type User struct {
+	Nick  string
+	Ident string
+	Host  string
+}
+
+type JoinEvent struct {
+	Event
+	Channel string
+	User    User
+	// Account is set when the user is logged in and the server sends it, with
+	// the extended-join or account-tag capabilities
+	Account string
+	// Realname is only sent with the extended-join capability
+	Realname string
+	// Self is set when we are the one joining
+	Self bool
+}
+
+type PartEvent struct {
+	Event
+	Channel string
+	User    User
+	Reason  string
+	// Self is set when we are the one leaving
+	Self bool
+}
+
+type KickEvent struct {
+	Event
+	Channel string
+	// User is the one kicking the target
+	User   User
+	Target string
+	Reason string
+	// Self is set when we are the one kicked
+	Self bool
+}
+
+type InviteEvent struct {
+	Event
+	Channel string
+	User    User
+	Target  string
+	// Self is set when we are the one invited
+	Self bool
+}
+
+// PrivmsgEvent is a message sent to a channel or to us, including the CTCP
+// messages.
+type PrivmsgEvent struct {
+	Event
+	User   User
+	Target string
+	// Text holds the parameters of the CTCP message when IsCTCP is set, its
+	// command being in CTCP
+	Text      string
+	IsChannel bool
+	IsCTCP    bool
+}
+
+// OnJoin calls the handler with the JOINs, ours included.
+func (c *Client) OnJoin(ctx context.Context, handler func(JoinEvent)) *stream.Observer {
+	return c.Each(ctx, JOIN, func(e Event) {
+		args := e.Message.Args
+		if len(args) < 1 {
+			return
+		}
+		event := JoinEvent{
+			Event:   e,
+			Channel: args[0],
+			User:    messageUser(e.Message),
+			Account: e.Message.Tags["account"],
+			Self:    c.isSelf(e.Message.Nick),
+		}
+		// "JOIN <channel> <account> :<realname>" with extended-join
+		if len(args) >= 3 {
+			if args[1] != "*" {
+				event.Account = args[1]
+			}
+			event.Realname = args[2]
+		}
+		handler(event)
+	})
+}
+
+func (c *Client) OnPart(ctx context.Context, handler func(PartEvent)) *stream.Observer {
+	return c.Each(ctx, PART, func(e Event) {
+		args := e.Message.Args
+		if len(args) < 1 {
+			return
+		}
+		event := PartEvent{
+			Event:   e,
+			Channel: args[0],
+			User:    messageUser(e.Message),
+			Self:    c.isSelf(e.Message.Nick),
+		}
+		if len(args) >= 2 {
+			event.Reason = args[1]
+		}
+		handler(event)
+	})
+}
+
+func (c *Client) OnKick(ctx context.Context, handler func(KickEvent)) *stream.Observer {
+	return c.Each(ctx, KICK, func(e Event) {
+		args := e.Message.Args
+		if len(args) < 2 {
+			return
+		}
+		event := KickEvent{
+			Event:   e,
+			Channel: args[0],
+			User:    messageUser(e.Message),
+			Target:  args[1],
+			Self:    c.isSelf(args[1]),
+		}
+		if len(args) >= 3 {
+			event.Reason = args[2]
+		}
+		handler(event)
+	})
+}
+
+func (c *Client) OnInvite(ctx context.Context, handler func(InviteEvent)) *stream.Observer {
+	return c.Each(ctx, INVITE, func(e Event) {
+		args := e.Message.Args
+		if len(args) < 2 {
+			return
+		}
+		handler(InviteEvent{
+			Event:   e,
+			Channel: args[1],
+			User:    messageUser(e.Message),
+			Target:  args[0],
+			Self:    c.isSelf(args[0]),
+		})
+	})
+}
+
+// OnPrivmsg calls the handler with the PRIVMSGs, the CTCP ones included. They
+// are taken from RAW to keep the plain and CTCP messages in order.
+func (c *Client) OnPrivmsg(ctx context.Context, handler func(PrivmsgEvent)) *stream.Observer {
+	return c.Each(ctx, RAW, func(e Event) {
+		args := e.Message.Args
+		if e.Message.Cmd != PRIVMSG || len(args) < 2 {
+			return
+		}
+		event := PrivmsgEvent{
+			Event:     e,
+			User:      messageUser(e.Message),
+			Target:    args[0],
+			Text:      args[len(args)-1],
+			IsChannel: c.isupport.IsChannel(args[0]),
+		}
+		if ctcp, ok := ParseCTCP(event.Text); ok {
+			event.CTCP = ctcp
+			event.Text = ctcp.Params
+			event.IsCTCP = true
+		}
+		if event.IsChannel {
+			event.Channel = args[0]
+		}
+		handler(event)
+	})
+}
+
+func messageUser(msg Message) User {
+	if msg.Nick == "" {
+		// Servers and services may only send a nick as source
+		return User{Nick: msg.Src}
+	}
+	return User{Nick: msg.Nick, Ident: msg.Ident, Host: msg.Host}
+}
@@ -0,0 +1,113 @@
+package irc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedEvents(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	ctx := context.Background()
+	joins := make(chan JoinEvent, 1)
+	parts := make(chan PartEvent, 1)
+	kicks := make(chan KickEvent, 1)
+	invites := make(chan InviteEvent, 1)
+	messages := make(chan PrivmsgEvent, 1)
+	c.OnJoin(ctx, func(e JoinEvent) { joins <- e })
+	c.OnPart(ctx, func(e PartEvent) { parts <- e })
+	c.OnKick(ctx, func(e KickEvent) { kicks <- e })
+	c.OnInvite(ctx, func(e InviteEvent) { invites <- e })
+	c.OnPrivmsg(ctx, func(e PrivmsgEvent) { messages <- e })
+
+	alice := User{Nick: "alice", Ident: "~alice", Host: "host"}
+
+	srv.send(":chatto!~chatto-irc@host JOIN #chatto")
+	join := <-joins
+	require.Equal("#chatto", join.Channel)
+	require.True(join.Self)
+	// extended-join
+	srv.send(":alice!~alice@host JOIN #chatto alice :Alice Liddell")
+	join = <-joins
+	require.Equal(alice, join.User)
+	require.Equal("alice", join.Account)
+	require.Equal("Alice Liddell", join.Realname)
+	require.False(join.Self)
+	srv.send("@account=bob :bob!~bob@host JOIN #chatto")
+	require.Equal("bob", (<-joins).Account)
+	srv.send(":carol!~carol@host JOIN #chatto * :Carol")
+	require.Equal("", (<-joins).Account)
+
+	srv.send(":alice!~alice@host PART #chatto :Bye")
+	part := <-parts
+	require.Equal(PartEvent{Event: part.Event, Channel: "#chatto", User: alice, Reason: "Bye"}, part)
+
+	srv.send(":alice!~alice@host KICK #chatto chatto :Out")
+	kick := <-kicks
+	require.Equal(KickEvent{Event: kick.Event, Channel: "#chatto", User: alice, Target: "chatto", Reason: "Out", Self: true}, kick)
+
+	srv.send(":alice!~alice@host INVITE chatto #go")
+	invite := <-invites
+	require.Equal(InviteEvent{Event: invite.Event, Channel: "#go", User: alice, Target: "chatto", Self: true}, invite)
+
+	srv.send(":alice!~alice@host PRIVMSG #chatto :Hello, world!")
+	msg := <-messages
+	require.Equal(alice, msg.User)
+	require.Equal("#chatto", msg.Target)
+	require.Equal("#chatto", msg.Channel)
+	require.Equal("Hello, world!", msg.Text)
+	require.True(msg.IsChannel)
+	require.False(msg.IsCTCP)
+
+	srv.send(":alice!~alice@host PRIVMSG chatto :\x01ACTION waves\x01")
+	msg = <-messages
+	require.Equal("chatto", msg.Target)
+	require.False(msg.IsChannel)
+	require.True(msg.IsCTCP)
+	require.Equal(CTCP_ACTION, msg.CTCP.Command)
+	require.Equal("waves", msg.Text)
+
+	// Malformed messages are skipped
+	srv.send(
+		":alice!~alice@host KICK #chatto",
+		":alice!~alice@host PRIVMSG #chatto",
+		":NickServ PRIVMSG chatto :Identify",
+	)
+	msg = <-messages
+	require.Equal(User{Nick: "NickServ"}, msg.User)
+	require.Equal("Identify", msg.Text)
+	require.Len(kicks, 0)
+
+	require.Nil(closeClient(c, srv))
+}
+
+func TestHandlerWaitingForReply(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	// A handler waiting for a reply doesn't keep the following lines from being read
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	errs := make(chan error, 1)
+	c.OnPrivmsg(ctx, func(e PrivmsgEvent) {
+		if e.Text == ".join" {
+			errs <- c.Join(ctx, "#foo")
+		}
+	})
+	srv.send(":alice!~alice@host PRIVMSG chatto :.join")
+	srv.expect("JOIN #foo")
+	srv.send(
+		":alice!~alice@host PRIVMSG chatto :1",
+		":alice!~alice@host PRIVMSG chatto :2",
+		":alice!~alice@host PRIVMSG chatto :3",
+		":chatto!~chatto-irc@host JOIN #foo",
+	)
+	require.Nil(<-errs)
+	require.Nil(closeClient(c, srv))
+}
@@ -0,0 +1,193 @@
+package irc
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFloodBurst    = 8
+	defaultFloodInterval = 500 * time.Millisecond
+)
+
+// Commands which bypass the flood control and jump the queue
+var priorityCommands = map[string]bool{
+	PING: true,
+	PONG: true,
+	QUIT: true,
+}
+
+// Commands which are queued fairly per target
+var targetedCommands = map[string]bool{
+	PRIVMSG: true,
+	NOTICE:  true,
+	TAGMSG:  true,
+}
+
+type FloodConfig struct {
+	// Burst is the number of lines sent without delay, defaults to 8 and
+	// disables the flood control when negative
+	Burst int
+	// Interval is the time needed to regain one line of the burst, defaults to 500ms
+	Interval time.Duration
+}
+
+type FloodStats struct {
+	// Depth is the number of lines waiting to be sent
+	Depth int
+	// Targets is the number of targets with queued lines
+	Targets int
+	// MaxDepth is the highest depth reached on the connection
+	MaxDepth int
+	// Sent is the number of lines sent on the connection
+	Sent uint64
+	// Throttled is the number of times sending got delayed by the rate limit
+	Throttled uint64
+}
+
+// sendQueue schedules outgoing lines with a token bucket. Priority lines are
+// always sent first, the other lines are sent round-robin between their targets.
+type sendQueue struct {
+	mu  sync.Mutex
+	cfg FloodConfig
+	// fold maps targets which only differ by case to the same queue
+	fold func(string) string
+
+	tokens   float64
+	refilled time.Time
+
+	priority []string
+	targets  map[string][]string
+	order    []string
+	stats    FloodStats
+}
+
+func newSendQueue(cfg FloodConfig, fold func(string) string) *sendQueue {
+	q := &sendQueue{cfg: cfg, fold: fold}
+	q.reset()
+	return q
+}
+
+func (q *sendQueue) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.tokens = float64(q.cfg.Burst)
+	q.refilled = time.Now()
+	q.priority = nil
+	q.targets = make(map[string][]string)
+	q.order = nil
+	q.stats = FloodStats{}
+}
+
+func (q *sendQueue) push(line string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cmd, target := classifyLine(line)
+	if q.fold != nil {
+		target = q.fold(target)
+	}
+	if priorityCommands[cmd] {
+		q.priority = append(q.priority, line)
+	} else {
+		if len(q.targets[target]) <= 0 {
+			q.order = append(q.order, target)
+		}
+		q.targets[target] = append(q.targets[target], line)
+	}
+	q.stats.Depth++
+	if q.stats.Depth > q.stats.MaxDepth {
+		q.stats.MaxDepth = q.stats.Depth
+	}
+}
+
+// next returns the next line to send, or the time to wait before one is allowed.
+func (q *sendQueue) next(now time.Time) (string, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.stats.Depth <= 0 {
+		return "", 0, false
+	}
+	q.refill(now)
+
+	if len(q.priority) > 0 {
+		line := q.priority[0]
+		q.priority = q.priority[1:]
+		if q.tokens >= 1 {
+			q.tokens--
+		}
+		q.sent()
+		return line, 0, true
+	}
+
+	if q.cfg.Burst >= 0 && q.tokens < 1 {
+		q.stats.Throttled++
+		wait := time.Duration((1 - q.tokens) * float64(q.cfg.Interval))
+		return "", wait, false
+	}
+	target := q.order[0]
+	lines := q.targets[target]
+	line := lines[0]
+	q.order = q.order[1:]
+	if len(lines) > 1 {
+		q.targets[target] = lines[1:]
+		q.order = append(q.order, target)
+	} else {
+		delete(q.targets, target)
+	}
+	if q.cfg.Burst >= 0 {
+		q.tokens--
+	}
+	q.sent()
+	return line, 0, true
+}
+
+func (q *sendQueue) refill(now time.Time) {
+	if q.cfg.Burst < 0 {
+		return
+	}
+	elapsed := now.Sub(q.refilled)
+	if elapsed <= 0 {
+		return
+	}
+	q.refilled = now
+	q.tokens += float64(elapsed) / float64(q.cfg.Interval)
+	if max := float64(q.cfg.Burst); q.tokens > max {
+		q.tokens = max
+	}
+}
+
+func (q *sendQueue) sent() {
+	q.stats.Depth--
+	q.stats.Sent++
+}
+
+func (q *sendQueue) snapshot() FloodStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.Targets = len(q.targets)
+	return stats
+}
+
+// classifyLine returns the command of the line and its target for fair queueing.
+func classifyLine(line string) (cmd string, target string) {
+	fields := strings.Fields(line)
+	// Skip the tags and the prefix
+	for len(fields) > 0 && (fields[0][0] == '@' || fields[0][0] == ':') {
+		fields = fields[1:]
+	}
+	if len(fields) <= 0 {
+		return "", ""
+	}
+	cmd = strings.ToUpper(fields[0])
+	if targetedCommands[cmd] && len(fields) > 1 {
+		target = fields[1]
+	}
+	return cmd, target
+}
+
+// FloodStats returns the state of the outgoing queue.
+func (c *Client) FloodStats() FloodStats {
+	return c.queue.snapshot()
+}
@@ -0,0 +1,75 @@
+package irc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendQueue(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	q := newSendQueue(FloodConfig{Burst: 2, Interval: 100 * time.Millisecond}, nil)
+	now := time.Now()
+	q.refilled = now
+
+	q.push("PRIVMSG #a :1\r\n")
+	q.push("PRIVMSG #a :2\r\n")
+	q.push("PRIVMSG #a :3\r\n")
+	q.push("@+draft/reply=x PRIVMSG #b :1\r\n")
+	q.push("PONG :token\r\n")
+	stats := q.snapshot()
+	assert.Equal(5, stats.Depth)
+	assert.Equal(2, stats.Targets)
+
+	next := func(at time.Duration, expected string) {
+		line, wait, ok := q.next(now.Add(at))
+		require.True(ok, "expected %q at %s, wait %s", expected, at, wait)
+		assert.Equal(expected, line)
+	}
+	wait := func(at time.Duration, expected time.Duration) {
+		_, wait, ok := q.next(now.Add(at))
+		require.False(ok)
+		assert.Equal(expected, wait)
+	}
+
+	// Priority lines jump the queue, the others are spread between targets
+	next(0, "PONG :token\r\n")
+	next(0, "PRIVMSG #a :1\r\n")
+	wait(0, 100*time.Millisecond)
+	wait(50*time.Millisecond, 50*time.Millisecond)
+	next(100*time.Millisecond, "@+draft/reply=x PRIVMSG #b :1\r\n")
+	next(200*time.Millisecond, "PRIVMSG #a :2\r\n")
+
+	// Priority lines are never delayed
+	q.push("QUIT\r\n")
+	next(200*time.Millisecond, "QUIT\r\n")
+
+	// The burst is regained over time but never exceeds its size
+	next(time.Second, "PRIVMSG #a :3\r\n")
+	_, _, ok := q.next(time.Now())
+	assert.False(ok)
+
+	stats = q.snapshot()
+	assert.Equal(0, stats.Depth)
+	assert.Equal(0, stats.Targets)
+	assert.Equal(5, stats.MaxDepth)
+	assert.Equal(uint64(6), stats.Sent)
+	assert.Equal(uint64(2), stats.Throttled)
+}
+
+func TestSendQueueUnlimited(t *testing.T) {
+	assert := assert.New(t)
+	q := newSendQueue(FloodConfig{Burst: -1}, nil)
+	for i := 0; i < 100; i++ {
+		q.push("PRIVMSG #a :flood\r\n")
+	}
+	for i := 0; i < 100; i++ {
+		_, _, ok := q.next(time.Now())
+		assert.True(ok)
+	}
+	assert.Equal(uint64(100), q.snapshot().Sent)
+}
@@ -0,0 +1,137 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Builder builds formatted text, stripping the formatting codes of the text
+// written to it so that user input can't alter the formatting.
+type Builder struct {
+	sb strings.Builder
+	// style is the one in effect at the end of the text built so far
+	style Style
+}
+
+// Format returns the spans as formatted text.
+func Format(spans []Span) string {
+	b := &Builder{}
+	for _, span := range spans {
+		b.Span(span.Style, span.Text)
+	}
+	return b.String()
+}
+
+// Text writes the text in the default style.
+func (b *Builder) Text(text string) *Builder {
+	return b.Span(Style{}, text)
+}
+
+func (b *Builder) Bold(text string) *Builder {
+	return b.Span(Style{Bold: true}, text)
+}
+
+func (b *Builder) Italic(text string) *Builder {
+	return b.Span(Style{Italic: true}, text)
+}
+
+func (b *Builder) Underline(text string) *Builder {
+	return b.Span(Style{Underline: true}, text)
+}
+
+func (b *Builder) Monospace(text string) *Builder {
+	return b.Span(Style{Monospace: true}, text)
+}
+
+// Color writes the text with the colors, bg being optional.
+func (b *Builder) Color(fg Color, text string, bg ...Color) *Builder {
+	style := Style{Foreground: fg}
+	if len(bg) > 0 {
+		style.Background = bg[0]
+	}
+	return b.Span(style, text)
+}
+
+// Span writes the text with the style.
+func (b *Builder) Span(style Style, text string) *Builder {
+	text = Strip(text)
+	if text == "" {
+		return b
+	}
+	if b.setStyle(style) && b.style.Background == NoColor && strings.HasPrefix(text, ",") {
+		// Keeps ",<digits>" from being read as the background color
+		b.sb.WriteString(BOLD + BOLD)
+	}
+	b.sb.WriteString(text)
+	return b
+}
+
+// String returns the formatted text, resetting the formatting at the end.
+func (b *Builder) String() string {
+	if b.style.IsZero() {
+		return b.sb.String()
+	}
+	return b.sb.String() + RESET
+}
+
+func (b *Builder) Len() int {
+	return b.sb.Len()
+}
+
+// setStyle writes the codes changing the current style to the given one,
+// returning whether they end with a color code.
+func (b *Builder) setStyle(style Style) bool {
+	if _, ok := hexCode(style.Foreground); !ok && style.Background.IsRGB() {
+		// The hex code can't set the background alone, so it is left out
+		style.Background = NoColor
+	}
+	cur := b.style
+	if cur == style {
+		return false
+	}
+	b.style = style
+	// Codes can't unset a single color, so start over when removing one
+	if (cur.Foreground != NoColor && style.Foreground == NoColor) ||
+		(cur.Background != NoColor && style.Background == NoColor) {
+		b.sb.WriteString(RESET)
+		cur = Style{}
+	}
+	toggle := func(from bool, to bool, code string) {
+		if from != to {
+			b.sb.WriteString(code)
+		}
+	}
+	toggle(cur.Bold, style.Bold, BOLD)
+	toggle(cur.Italic, style.Italic, ITALIC)
+	toggle(cur.Underline, style.Underline, UNDERLINE)
+	toggle(cur.Strikethrough, style.Strikethrough, STRIKETHROUGH)
+	toggle(cur.Monospace, style.Monospace, MONOSPACE)
+	toggle(cur.Reverse, style.Reverse, REVERSE)
+	if cur.Foreground == style.Foreground && cur.Background == style.Background {
+		return false
+	}
+	fg, bg := style.Foreground, style.Background
+	if fg.IsRGB() || bg.IsRGB() {
+		code, _ := hexCode(fg)
+		b.sb.WriteString(HEX_COLOR + code)
+		if code, ok := hexCode(bg); ok {
+			b.sb.WriteString("," + code)
+		}
+		return true
+	}
+	b.sb.WriteString(COLOR + fg.code())
+	if bg != NoColor {
+		b.sb.WriteString("," + bg.code())
+	}
+	return true
+}
+
+// hexCode returns the color as hex for the \x04 code, false for NoColor since
+// the code has no default color.
+func hexCode(c Color) (string, bool) {
+	rgb, ok := c.RGB()
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%06X", rgb), true
+}
@@ -0,0 +1,107 @@
+package format
+
+import "fmt"
+
+// Color is one of the 99 mIRC colors or a hex color. The zero value is no
+// color, leaving the client default.
+type Color uint32
+
+// NoColor is the client default color, also used for the color code 99
+const NoColor Color = 0
+
+// The 16 common mIRC colors
+const (
+	White Color = iota + 1
+	Black
+	Blue
+	Green
+	Red
+	Brown
+	Magenta
+	Orange
+	Yellow
+	LightGreen
+	Cyan
+	LightCyan
+	LightBlue
+	Pink
+	Grey
+	LightGrey
+)
+
+// rgbFlag marks the hex colors apart from the mIRC codes
+const rgbFlag = 1 << 24
+
+// palette holds the RGB values of the mIRC colors by code
+var palette = [99]uint32{
+	0xffffff, 0x000000, 0x00007f, 0x009300, 0xff0000, 0x7f0000, 0x9c009c, 0xfc7f00,
+	0xffff00, 0x00fc00, 0x009393, 0x00ffff, 0x0000fc, 0xff00ff, 0x7f7f7f, 0xd2d2d2,
+	0x470000, 0x472100, 0x474700, 0x324700, 0x004700, 0x00472c, 0x004747, 0x002747,
+	0x000047, 0x2e0047, 0x470047, 0x47002a, 0x740000, 0x743a00, 0x747400, 0x517400,
+	0x007400, 0x007449, 0x007474, 0x004074, 0x000074, 0x4b0074, 0x740074, 0x740045,
+	0xb50000, 0xb56300, 0xb5b500, 0x7db500, 0x00b500, 0x00b571, 0x00b5b5, 0x0063b5,
+	0x0000b5, 0x7500b5, 0xb500b5, 0xb5006b, 0xff0000, 0xff8c00, 0xffff00, 0xb2ff00,
+	0x00ff00, 0x00ffa0, 0x00ffff, 0x008cff, 0x0000ff, 0xa500ff, 0xff00ff, 0xff0098,
+	0xff5959, 0xffb459, 0xffff71, 0xcfff60, 0x6fff6f, 0x65ffc9, 0x6dffff, 0x59b4ff,
+	0x5959ff, 0xc459ff, 0xff66ff, 0xff59bc, 0xff9c9c, 0xffd39c, 0xffff9c, 0xe2ff9c,
+	0x9cff9c, 0x9cffdb, 0x9cffff, 0x9cd3ff, 0x9c9cff, 0xdc9cff, 0xff9cff, 0xff94d3,
+	0x000000, 0x131313, 0x282828, 0x363636, 0x4d4d4d, 0x656565, 0x818181, 0x9f9f9f,
+	0xbcbcbc, 0xe2e2e2, 0xffffff,
+}
+
+// Code returns the mIRC color of the code, NoColor when out of range.
+func Code(code int) Color {
+	if code < 0 || code >= len(palette) {
+		return NoColor
+	}
+	return Color(code + 1)
+}
+
+// RGB returns the hex color, as set with the \x04 code.
+func RGB(r uint8, g uint8, b uint8) Color {
+	return Color(rgbFlag | uint32(r)<<16 | uint32(g)<<8 | uint32(b))
+}
+
+// Code returns the mIRC code of the color, false for hex colors and NoColor.
+func (c Color) Code() (int, bool) {
+	if c == NoColor || c.IsRGB() {
+		return 0, false
+	}
+	return int(c) - 1, true
+}
+
+func (c Color) IsRGB() bool {
+	return c&rgbFlag != 0
+}
+
+// RGB returns the color as 0xRRGGBB, false for NoColor.
+func (c Color) RGB() (uint32, bool) {
+	if c == NoColor {
+		return 0, false
+	}
+	if c.IsRGB() {
+		return uint32(c) &^ rgbFlag, true
+	}
+	return palette[c-1], true
+}
+
+// Hex returns the color like #ff0000, or an empty string for NoColor.
+func (c Color) Hex() string {
+	rgb, ok := c.RGB()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("#%06x", rgb)
+}
+
+// code returns the formatting code setting the color, with two digits so the
+// text following it can't be taken as part of the color.
+func (c Color) code() string {
+	if code, ok := c.Code(); ok {
+		return fmt.Sprintf("%02d", code)
+	}
+	if rgb, ok := c.RGB(); ok {
+		return fmt.Sprintf("%06X", rgb)
+	}
+	return "99"
+}
@@ -0,0 +1,160 @@
+// Package format handles the mIRC formatting codes of the messages: parsing
+// them into styled spans, stripping them, building formatted text and
+// converting it for terminals and HTML.
+package format
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Formatting control codes
+const (
+	BOLD          = "\x02"
+	COLOR         = "\x03"
+	HEX_COLOR     = "\x04"
+	RESET         = "\x0F"
+	MONOSPACE     = "\x11"
+	REVERSE       = "\x16"
+	ITALIC        = "\x1D"
+	STRIKETHROUGH = "\x1E"
+	UNDERLINE     = "\x1F"
+)
+
+// Style is the formatting applied to a span of text.
+type Style struct {
+	Bold          bool
+	Italic        bool
+	Underline     bool
+	Strikethrough bool
+	Monospace     bool
+	Reverse       bool
+	Foreground    Color
+	Background    Color
+}
+
+// IsZero reports whether the style leaves the text unformatted.
+func (s Style) IsZero() bool {
+	return s == Style{}
+}
+
+// Span is a run of text sharing the same style.
+type Span struct {
+	Style
+	Text string
+}
+
+// Parse splits the text into spans of the same style, adjacent spans always
+// having different styles.
+func Parse(text string) []Span {
+	spans := make([]Span, 0)
+	style := Style{}
+	start := 0
+	flush := func(end int) {
+		if end <= start {
+			return
+		}
+		if n := len(spans); n > 0 && spans[n-1].Style == style {
+			spans[n-1].Text += text[start:end]
+		} else {
+			spans = append(spans, Span{Style: style, Text: text[start:end]})
+		}
+	}
+	for i := 0; i < len(text); {
+		n := 1
+		switch text[i : i+1] {
+		case BOLD:
+			flush(i)
+			style.Bold = !style.Bold
+		case ITALIC:
+			flush(i)
+			style.Italic = !style.Italic
+		case UNDERLINE:
+			flush(i)
+			style.Underline = !style.Underline
+		case STRIKETHROUGH:
+			flush(i)
+			style.Strikethrough = !style.Strikethrough
+		case MONOSPACE:
+			flush(i)
+			style.Monospace = !style.Monospace
+		case REVERSE:
+			flush(i)
+			style.Reverse = !style.Reverse
+		case RESET:
+			flush(i)
+			style = Style{}
+		case COLOR:
+			flush(i)
+			n += parseColors(text[i+1:], &style, parseCode)
+		case HEX_COLOR:
+			flush(i)
+			n += parseColors(text[i+1:], &style, parseHex)
+		default:
+			n = 0
+		}
+		if n == 0 {
+			i++
+			continue
+		}
+		i += n
+		start = i
+	}
+	flush(len(text))
+	return spans
+}
+
+// Strip returns the text without its formatting codes.
+func Strip(text string) string {
+	if !strings.ContainsAny(text, BOLD+COLOR+HEX_COLOR+RESET+MONOSPACE+REVERSE+ITALIC+STRIKETHROUGH+UNDERLINE) {
+		return text
+	}
+	sb := strings.Builder{}
+	for _, span := range Parse(text) {
+		sb.WriteString(span.Text)
+	}
+	return sb.String()
+}
+
+// parseColors parses the "<fg>[,<bg>]" following a color code into the style
+// and returns its length. Colors are removed when there's none.
+func parseColors(s string, style *Style, parse func(string) (Color, int)) int {
+	fg, n := parse(s)
+	if n == 0 {
+		style.Foreground, style.Background = NoColor, NoColor
+		return 0
+	}
+	style.Foreground = fg
+	if n < len(s) && s[n] == ',' {
+		if bg, m := parse(s[n+1:]); m > 0 {
+			style.Background = bg
+			n += m + 1
+		}
+	}
+	return n
+}
+
+// parseCode parses a color code of one or two digits.
+func parseCode(s string) (Color, int) {
+	n := 0
+	for n < 2 && n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return NoColor, 0
+	}
+	code, _ := strconv.Atoi(s[:n])
+	return Code(code), n
+}
+
+// parseHex parses a RRGGBB hex color.
+func parseHex(s string) (Color, int) {
+	if len(s) < 6 {
+		return NoColor, 0
+	}
+	rgb, err := strconv.ParseUint(s[:6], 16, 32)
+	if err != nil {
+		return NoColor, 0
+	}
+	return RGB(uint8(rgb>>16), uint8(rgb>>8), uint8(rgb)), 6
+}
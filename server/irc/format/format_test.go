@@ -0,0 +1,125 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]Span{{Text: "Hello, world!"}}, Parse("Hello, world!"))
+	assert.Empty(Parse(""))
+	assert.Equal([]Span{
+		{Text: "a "},
+		{Style: Style{Bold: true}, Text: "bold"},
+		{Text: " "},
+		{Style: Style{Bold: true, Italic: true, Underline: true}, Text: "all"},
+		{Text: " reset"},
+	}, Parse("a \x02bold\x02 \x02\x1D\x1Fall\x0F reset"))
+
+	// Colors with one or two digits and an optional background
+	assert.Equal([]Span{
+		{Style: Style{Foreground: Red}, Text: "red"},
+		{Style: Style{Foreground: Blue, Background: Yellow}, Text: "blue"},
+		{Style: Style{Foreground: Code(52), Background: Yellow}, Text: "5"},
+		{Text: "plain,"},
+		{Style: Style{Foreground: Green}, Text: ","},
+	}, Parse("\x034red\x0302,08blue\x03525\x03plain,\x033,"))
+	// 99 is the default color
+	assert.Equal([]Span{{Style: Style{Background: Black}, Text: "text"}}, Parse("\x0399,1text"))
+
+	// Hex colors
+	assert.Equal([]Span{
+		{Style: Style{Foreground: RGB(0xff, 0x88, 0x00), Background: RGB(0, 0, 0)}, Text: "hex"},
+		{Text: "ABC"},
+	}, Parse("\x04FF8800,000000hex\x04ABC"))
+
+	assert.Equal([]Span{
+		{Style: Style{Strikethrough: true, Monospace: true, Reverse: true}, Text: "code"},
+	}, Parse("\x1E\x11\x16code"))
+}
+
+func TestStrip(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("Hello, world!", Strip("Hello, world!"))
+	assert.Equal("bold red 5 hex", Strip("\x02bold\x02 \x034,1red\x03 \x03555\x0F \x04FF0000hex"))
+	assert.Equal("", Strip("\x02\x03\x1D"))
+}
+
+func TestColor(t *testing.T) {
+	assert := assert.New(t)
+
+	code, ok := Red.Code()
+	assert.True(ok)
+	assert.Equal(4, code)
+	assert.Equal("#ff0000", Red.Hex())
+	assert.Equal("#ffff9c", Code(78).Hex())
+	assert.Equal(NoColor, Code(99))
+	assert.Equal("", NoColor.Hex())
+
+	hex := RGB(0x12, 0x34, 0x56)
+	assert.True(hex.IsRGB())
+	_, ok = hex.Code()
+	assert.False(ok)
+	assert.Equal("#123456", hex.Hex())
+}
+
+func TestBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	b := &Builder{}
+	b.Text("Hi ").Bold("alice").Text(", ").Color(Red, "5 new", Yellow).Text(" messages")
+	assert.Equal("Hi \x02alice\x02, \x0304,085 new\x0F messages", b.String())
+
+	// The formatting codes of the text are stripped
+	b = &Builder{}
+	b.Italic("\x02bold\x02?").Text("\x0304red")
+	assert.Equal("\x1Dbold?\x1Dred", b.String())
+
+	// A comma can't be taken as the background color
+	b = &Builder{}
+	b.Color(Green, ",5")
+	assert.Equal("\x0303\x02\x02,5\x0F", b.String())
+	assert.Equal([]Span{{Style: Style{Foreground: Green}, Text: ",5"}}, Parse(b.String()))
+
+	// Hex colors switch to the hex code for both colors
+	b = &Builder{}
+	b.Color(RGB(0xff, 0x88, 0), "orange", Black).Monospace("code")
+	assert.Equal("\x04FF8800,000000orange\x0F\x11code\x0F", b.String())
+	b = &Builder{}
+	b.Color(Red, "red", RGB(0x12, 0x34, 0x56)).Color(RGB(0xff, 0x88, 0), "orange")
+	assert.Equal("\x04FF0000,123456red\x0F\x04FF8800orange\x0F", b.String())
+	// The hex background can't be set without a foreground
+	b = &Builder{}
+	b.Color(NoColor, "plain", RGB(0x12, 0x34, 0x56)).Color(Blue, "blue", RGB(0x12, 0x34, 0x56))
+	assert.Equal("plain\x0400007F,123456blue\x0F", b.String())
+
+	// Formatted text parses back to the same spans
+	spans := []Span{
+		{Style: Style{Bold: true, Foreground: Blue}, Text: "1"},
+		{Style: Style{Bold: true}, Text: "2"},
+		{Style: Style{Underline: true, Foreground: Code(40), Background: Code(41)}, Text: "3"},
+		{Style: Style{Strikethrough: true, Reverse: true, Foreground: RGB(1, 2, 3)}, Text: "4"},
+		{Text: "5"},
+	}
+	assert.Equal(spans, Parse(Format(spans)))
+}
+
+func TestANSI(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("plain", ANSI("plain"))
+	assert.Equal("\x1b[0;1mbold\x1b[0m plain", ANSI("\x02bold\x02 plain"))
+	assert.Equal("\x1b[0;91;44mred\x1b[0;4;38;2;255;136;0morange\x1b[0m", ANSI("\x034,2red\x0F\x1F\x04FF8800orange"))
+}
+
+func TestHTML(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("a &lt;b&gt;", HTML("a <b>"))
+	assert.Equal(`<span style="font-weight:bold;text-decoration:underline line-through">x</span> `+
+		`<span style="color:#ff0000;background-color:#ffff00">&amp;</span>`,
+		HTML("\x02\x1F\x1Ex\x0F \x034,8&"))
+	assert.Equal(`<span style="font-family:monospace;color:#ffffff;background-color:#000000">rev</span>`,
+		HTML("\x11\x16rev"))
+}
@@ -0,0 +1,124 @@
+package format
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+const ansiReset = "\x1b[0m"
+
+// ansiColors holds the ANSI colors closest to the 16 common mIRC colors, the
+// others being sent as 24-bit colors.
+var ansiColors = [16]int{97, 30, 34, 32, 91, 31, 35, 33, 93, 92, 36, 96, 94, 95, 90, 37}
+
+// ANSI converts the formatted text to ANSI escape sequences for terminals.
+func ANSI(text string) string {
+	sb := strings.Builder{}
+	styled := false
+	for _, span := range Parse(text) {
+		if span.IsZero() {
+			if styled {
+				sb.WriteString(ansiReset)
+			}
+		} else {
+			sb.WriteString("\x1b[" + strings.Join(ansiCodes(span.Style), ";") + "m")
+		}
+		styled = !span.IsZero()
+		sb.WriteString(span.Text)
+	}
+	if styled {
+		sb.WriteString(ansiReset)
+	}
+	return sb.String()
+}
+
+func ansiCodes(style Style) []string {
+	// Starts from a reset since the previous style may have attributes to remove
+	codes := []string{"0"}
+	add := func(set bool, code string) {
+		if set {
+			codes = append(codes, code)
+		}
+	}
+	add(style.Bold, "1")
+	add(style.Italic, "3")
+	add(style.Underline, "4")
+	add(style.Reverse, "7")
+	add(style.Strikethrough, "9")
+	if style.Foreground != NoColor {
+		codes = append(codes, ansiColor(style.Foreground, 0))
+	}
+	if style.Background != NoColor {
+		codes = append(codes, ansiColor(style.Background, 10))
+	}
+	return codes
+}
+
+// ansiColor returns the code of the color, offset by 10 for backgrounds.
+func ansiColor(c Color, offset int) string {
+	if code, ok := c.Code(); ok && code < len(ansiColors) {
+		return strconv.Itoa(ansiColors[code] + offset)
+	}
+	rgb, _ := c.RGB()
+	return fmt.Sprintf("%d;2;%d;%d;%d", 38+offset, rgb>>16&0xff, rgb>>8&0xff, rgb&0xff)
+}
+
+// HTML converts the formatted text to HTML, escaping it and wrapping the
+// styled spans into <span> elements with inline styles.
+func HTML(text string) string {
+	sb := strings.Builder{}
+	for _, span := range Parse(text) {
+		escaped := html.EscapeString(span.Text)
+		if span.IsZero() {
+			sb.WriteString(escaped)
+			continue
+		}
+		sb.WriteString(`<span style="` + strings.Join(cssProperties(span.Style), ";") + `">`)
+		sb.WriteString(escaped)
+		sb.WriteString("</span>")
+	}
+	return sb.String()
+}
+
+func cssProperties(style Style) []string {
+	props := make([]string, 0)
+	if style.Bold {
+		props = append(props, "font-weight:bold")
+	}
+	if style.Italic {
+		props = append(props, "font-style:italic")
+	}
+	decorations := make([]string, 0)
+	if style.Underline {
+		decorations = append(decorations, "underline")
+	}
+	if style.Strikethrough {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		props = append(props, "text-decoration:"+strings.Join(decorations, " "))
+	}
+	if style.Monospace {
+		props = append(props, "font-family:monospace")
+	}
+	fg, bg := style.Foreground, style.Background
+	if style.Reverse {
+		// Swapped with the usual black on white when unset
+		if fg == NoColor {
+			fg = Black
+		}
+		if bg == NoColor {
+			bg = White
+		}
+		fg, bg = bg, fg
+	}
+	if fg != NoColor {
+		props = append(props, "color:"+fg.Hex())
+	}
+	if bg != NoColor {
+		props = append(props, "background-color:"+bg.Hex())
+	}
+	return props
+}
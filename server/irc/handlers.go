@@ -2,6 +2,8 @@ package irc
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -13,21 +15,225 @@ type handlers struct {
 }
 
 var intHandlers = map[string]intHandlerFunc{
-	PING: (*handlers).ping,
+	RAW:       (*handlers).raw,
+	CONNECTED: (*handlers).connected,
+	PING:      (*handlers).ping,
+	PONG:      (*handlers).pong,
+	CAP:       (*handlers).cap,
+	CTCP:      (*handlers).ctcp,
+
+	RPL_ISUPPORT: (*handlers).isupport,
+}
+
+// Handlers of the session and channel state, which depend on each other and
+// are thus run in order from the RAW stream.
+var stateHandlers = map[string]intHandlerFunc{
+	JOIN:  (*handlers).join,
+	PART:  (*handlers).part,
+	KICK:  (*handlers).kick,
+	NICK:  (*handlers).nick,
+	QUIT:  (*handlers).state,
+	TOPIC: (*handlers).state,
+	MODE:  (*handlers).mode,
+
+	CHGHOST:           (*handlers).chghost,
+	RPL_HOSTHIDDEN:    (*handlers).hostHidden,
+	RPL_UMODEIS:       (*handlers).userModes,
+	RPL_CHANNELMODEIS: (*handlers).state,
+	RPL_TOPIC:         (*handlers).state,
+	RPL_TOPICWHOTIME:  (*handlers).state,
+	RPL_NAMREPLY:      (*handlers).state,
+	RPL_ENDOFNAMES:    (*handlers).state,
 }
 
 func registerInternalHandlers(ctx context.Context, c *Client) {
 	h := &handlers{ctx}
 	for name, handler := range intHandlers {
+		handler := handler
 		c.Each(ctx, name, func(e Event) {
 			handler(h, e)
 		})
 	}
 }
 
-func (h *handlers) ping(e Event) {
+func (h *handlers) raw(e Event) {
+	if handler, ok := stateHandlers[e.Message.Cmd]; ok {
+		handler(h, e)
+	}
+	e.Client.decoder.handle(e.Client, e.Message)
+}
+
+func (h *handlers) state(e Event) {
+	e.Client.updateState(e.Message)
+}
+
+func (h *handlers) connected(e Event) {
 	client := e.Client
-	if err := client.Pong(h.context, client.nick); err != nil {
+	if client.cfg.Nicks.Reclaim && !client.isSelf(client.preferredNick()) {
+		go client.reclaimNick(h.context)
+	}
+}
+
+func (h *handlers) ping(e Event) {
+	client, args := e.Client, e.Message.Args
+	token := client.CurrentNick()
+	if len(args) > 0 {
+		token = args[len(args)-1]
+	}
+	if err := client.Pong(h.context, token); err != nil {
 		log.Errorf("Error replying PING: %+v", err)
 	}
 }
+
+func (h *handlers) pong(e Event) {
+	client, args := e.Client, e.Message.Args
+	if len(args) < 1 {
+		return
+	}
+	if client.keepalive.pong(args[len(args)-1]) {
+		client.notify(LAG, e.Message)
+	}
+}
+
+func (h *handlers) cap(e Event) {
+	client, args := e.Client, e.Message.Args
+	if len(args) < 3 {
+		return
+	}
+	caps := args[len(args)-1]
+	switch args[1] {
+	case CAP_ACK:
+		client.caps.acknowledge(caps)
+	case CAP_DEL:
+		client.caps.withdraw(caps)
+	case CAP_NEW:
+		client.caps.advertise(caps)
+		wanted := client.caps.wanted(client.cfg.Caps)
+		if len(wanted) <= 0 {
+			return
+		}
+		if err := client.Command(h.context, CAP, CAP_REQ, ":"+strings.Join(wanted, " ")); err != nil {
+			log.Errorf("Error requesting capabilities: %+v", err)
+		}
+	}
+}
+
+// ctcp answers the CTCP queries sent by others with the automatic replies.
+func (h *handlers) ctcp(e Event) {
+	client, msg := e.Client, e.Message
+	if msg.Nick == "" || client.isSelf(msg.Nick) {
+		return
+	}
+	reply, ok := client.ctcpReplies[e.CTCP.Command]
+	if !ok {
+		return
+	}
+	params, ok := reply(e)
+	if !ok {
+		return
+	}
+	if !client.ctcpLimiter.allow(time.Now()) {
+		log.Debugf("Not replying CTCP %s from %s over the rate limit", e.CTCP.Command, msg.Nick)
+		return
+	}
+	if err := client.CTCPReply(h.context, msg.Nick, e.CTCP.Command, params); err != nil {
+		log.Errorf("Error replying CTCP %s: %+v", e.CTCP.Command, err)
+	}
+}
+
+func (h *handlers) join(e Event) {
+	client, msg := e.Client, e.Message
+	if len(msg.Args) > 0 && client.isSelf(msg.Nick) {
+		client.trackJoin(msg.Args[0])
+		client.trackSource(msg.Src)
+	}
+	client.updateState(msg)
+}
+
+func (h *handlers) part(e Event) {
+	client, msg := e.Client, e.Message
+	client.updateState(msg)
+	if len(msg.Args) > 0 && client.isSelf(msg.Nick) {
+		client.trackPart(msg.Args[0])
+	}
+}
+
+func (h *handlers) kick(e Event) {
+	client, msg := e.Client, e.Message
+	client.updateState(msg)
+	if len(msg.Args) > 1 && client.isSelf(msg.Args[1]) {
+		client.trackPart(msg.Args[0])
+	}
+}
+
+func (h *handlers) nick(e Event) {
+	client, msg := e.Client, e.Message
+	client.updateState(msg)
+	if len(msg.Args) > 0 && client.isSelf(msg.Nick) {
+		client.trackNick(msg.Args[0])
+	}
+}
+
+func (h *handlers) mode(e Event) {
+	client, msg := e.Client, e.Message
+	if len(msg.Args) < 2 {
+		return
+	}
+	target := msg.Args[0]
+	if !client.isupport.IsChannel(target) {
+		if !client.isSelf(target) {
+			return
+		}
+		changes := ParseUserModeChanges(msg.Args[1])
+		client.applyUserModes(changes, false)
+		client.notifyEvent(USER_MODE, Event{Message: msg, Modes: changes})
+		return
+	}
+
+	client.updateState(msg)
+	changes := client.isupport.ParseModeChanges(msg.Args[1:])
+	client.notifyEvent(CHANNEL_MODE, Event{Message: msg, Channel: target, Modes: changes})
+	for _, change := range changes {
+		names, ok := modeEvents[change.Mode]
+		if !ok {
+			continue
+		}
+		name := names[0]
+		if change.Add {
+			name = names[1]
+		}
+		client.notifyEvent(name, Event{Message: msg, Channel: target, Modes: []ModeChange{change}})
+	}
+}
+
+func (h *handlers) userModes(e Event) {
+	client, args := e.Client, e.Message.Args
+	if len(args) < 2 {
+		return
+	}
+	client.applyUserModes(ParseUserModeChanges(args[1]), true)
+}
+
+func (h *handlers) chghost(e Event) {
+	client, msg := e.Client, e.Message
+	if len(msg.Args) < 2 || !client.isSelf(msg.Nick) {
+		return
+	}
+	client.trackHost(msg.Args[0], msg.Args[1])
+}
+
+func (h *handlers) hostHidden(e Event) {
+	client, args := e.Client, e.Message.Args
+	if len(args) < 2 {
+		return
+	}
+	client.trackHost("", args[1])
+}
+
+func (h *handlers) isupport(e Event) {
+	args := e.Message.Args
+	if len(args) < 3 {
+		return
+	}
+	e.Client.updateISupport(args[1 : len(args)-1])
+}
@@ -0,0 +1,290 @@
+package irc
+
+import (
+	"chatto/irc/casemapping"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultChanTypes   = "#&"
+	defaultPrefixModes = "ov"
+	defaultPrefixes    = "@+"
+	defaultChanModes   = "beI,k,l,imnpst"
+	defaultCaseMapping = "rfc1459"
+	defaultNickLen     = 9
+	defaultChannelLen  = 200
+	defaultModes       = 3
+)
+
+// ChanModes holds the channel modes by type as advertised by CHANMODES.
+type ChanModes struct {
+	// A modes add or remove an address to a list and always take a parameter
+	A string
+	// B modes change a setting and always take a parameter
+	B string
+	// C modes change a setting and only take a parameter when set
+	C string
+	// D modes change a setting and never take a parameter
+	D string
+}
+
+// ISupport holds the server features advertised by RPL_ISUPPORT (005).
+// Accessors return the RFC defaults for tokens which were not advertised.
+type ISupport struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+func newISupport() *ISupport {
+	return &ISupport{
+		tokens: make(map[string]string),
+	}
+}
+
+func (i *ISupport) reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.tokens = make(map[string]string)
+}
+
+// parse applies the tokens of a RPL_ISUPPORT reply, without the target and
+// the trailing text.
+func (i *ISupport) parse(tokens []string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, token := range tokens {
+		if token == "" {
+			continue
+		}
+		if token[0] == '-' {
+			delete(i.tokens, strings.ToUpper(token[1:]))
+			continue
+		}
+		name, value := token, ""
+		if idx := strings.Index(token, "="); idx != -1 {
+			name, value = token[:idx], unescapeISupportValue(token[idx+1:])
+		}
+		i.tokens[strings.ToUpper(name)] = value
+	}
+}
+
+// Get returns the raw value of the token and whether it was advertised.
+func (i *ISupport) Get(name string) (string, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	v, ok := i.tokens[strings.ToUpper(name)]
+	return v, ok
+}
+
+// Has reports whether the token was advertised.
+func (i *ISupport) Has(name string) bool {
+	_, ok := i.Get(name)
+	return ok
+}
+
+// Tokens returns a copy of all the advertised tokens.
+func (i *ISupport) Tokens() map[string]string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	result := make(map[string]string, len(i.tokens))
+	for name, value := range i.tokens {
+		result[name] = value
+	}
+	return result
+}
+
+func (i *ISupport) Network() string {
+	v, _ := i.Get("NETWORK")
+	return v
+}
+
+func (i *ISupport) CaseMapping() string {
+	return i.stringOr("CASEMAPPING", defaultCaseMapping)
+}
+
+// Mapping returns the casemapping used to compare nicks and channel names.
+func (i *ISupport) Mapping() casemapping.CaseMapping {
+	return casemapping.Parse(i.CaseMapping())
+}
+
+// Fold returns the lowercase form of a nick or channel name on the server.
+func (i *ISupport) Fold(name string) string {
+	return i.Mapping().Fold(name)
+}
+
+// EqualFold reports whether both nicks or channel names are the same on the server.
+func (i *ISupport) EqualFold(a string, b string) bool {
+	return i.Mapping().Equal(a, b)
+}
+
+func (i *ISupport) ChanTypes() string {
+	return i.stringOr("CHANTYPES", defaultChanTypes)
+}
+
+// IsChannel reports whether the name starts with one of the channel types.
+func (i *ISupport) IsChannel(name string) bool {
+	return name != "" && strings.IndexByte(i.ChanTypes(), name[0]) != -1
+}
+
+// Prefix returns the channel membership modes and their matching prefixes,
+// ordered from the highest rank, e.g. "ov" and "@+".
+func (i *ISupport) Prefix() (modes string, prefixes string) {
+	v, ok := i.Get("PREFIX")
+	if !ok {
+		return defaultPrefixModes, defaultPrefixes
+	}
+	if !strings.HasPrefix(v, "(") {
+		return "", ""
+	}
+	idx := strings.Index(v, ")")
+	if idx == -1 || idx-1 != len(v)-idx-1 {
+		return defaultPrefixModes, defaultPrefixes
+	}
+	return v[1:idx], v[idx+1:]
+}
+
+// PrefixMode returns the membership mode of the prefix, like 'o' for '@'.
+func (i *ISupport) PrefixMode(prefix byte) (byte, bool) {
+	modes, prefixes := i.Prefix()
+	if idx := strings.IndexByte(prefixes, prefix); idx != -1 {
+		return modes[idx], true
+	}
+	return 0, false
+}
+
+// ModePrefix returns the prefix of the membership mode, like '@' for 'o'.
+func (i *ISupport) ModePrefix(mode byte) (byte, bool) {
+	modes, prefixes := i.Prefix()
+	if idx := strings.IndexByte(modes, mode); idx != -1 {
+		return prefixes[idx], true
+	}
+	return 0, false
+}
+
+func (i *ISupport) ChanModes() ChanModes {
+	parts := strings.SplitN(i.stringOr("CHANMODES", defaultChanModes), ",", 4)
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+	return ChanModes{A: parts[0], B: parts[1], C: parts[2], D: parts[3]}
+}
+
+// StatusMsg returns the prefixes which can be used to message channel members by status.
+func (i *ISupport) StatusMsg() string {
+	v, _ := i.Get("STATUSMSG")
+	return v
+}
+
+func (i *ISupport) NickLen() int {
+	return i.intOr("NICKLEN", defaultNickLen)
+}
+
+func (i *ISupport) ChannelLen() int {
+	return i.intOr("CHANNELLEN", defaultChannelLen)
+}
+
+// TopicLen returns the maximum topic length, zero when unlimited.
+func (i *ISupport) TopicLen() int {
+	return i.intOr("TOPICLEN", 0)
+}
+
+// AwayLen returns the maximum away message length, zero when unlimited.
+func (i *ISupport) AwayLen() int {
+	return i.intOr("AWAYLEN", 0)
+}
+
+// KickLen returns the maximum kick reason length, zero when unlimited.
+func (i *ISupport) KickLen() int {
+	return i.intOr("KICKLEN", 0)
+}
+
+// LineLen returns the maximum line length including the trailing CRLF.
+func (i *ISupport) LineLen() int {
+	return i.intOr("LINELEN", maxLineLength)
+}
+
+// Modes returns the maximum number of parameterized modes per MODE command.
+func (i *ISupport) Modes() int {
+	v, ok := i.Get("MODES")
+	if !ok {
+		return defaultModes
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		return n
+	}
+	// An empty value means there is no limit
+	return 0
+}
+
+// TargMax returns the maximum number of targets for the command and whether
+// it is limited at all.
+func (i *ISupport) TargMax(cmd string) (int, bool) {
+	v, ok := i.Get("TARGMAX")
+	if !ok {
+		return 0, false
+	}
+	for _, part := range strings.Split(v, ",") {
+		idx := strings.Index(part, ":")
+		if idx == -1 || !strings.EqualFold(part[:idx], cmd) {
+			continue
+		}
+		if n, err := strconv.Atoi(part[idx+1:]); err == nil {
+			return n, true
+		}
+		return 0, false
+	}
+	return 0, false
+}
+
+// stringOr returns the value of the token, which may be advertised empty on
+// purpose, or the fallback when it wasn't advertised.
+func (i *ISupport) stringOr(name string, fallback string) string {
+	if v, ok := i.Get(name); ok {
+		return v
+	}
+	return fallback
+}
+
+func (i *ISupport) intOr(name string, fallback int) int {
+	if v, ok := i.Get(name); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// unescapeISupportValue decodes the \xHH escapes allowed in token values.
+func unescapeISupportValue(s string) string {
+	if !strings.Contains(s, "\\x") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if b, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				sb.WriteByte(byte(b))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// ISupport returns the features advertised by the server.
+func (c *Client) ISupport() *ISupport {
+	return c.isupport
+}
+
+// updateISupport parses the advertised tokens and applies a change of the
+// casemapping to the names known so far.
+func (c *Client) updateISupport(tokens []string) {
+	c.isupport.parse(tokens)
+	mapping := c.isupport.Mapping()
+	c.channels.SetMapping(mapping)
+	c.state.setMapping(mapping)
+}
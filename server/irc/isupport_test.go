@@ -0,0 +1,103 @@
+package irc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestISupport(t *testing.T) {
+	assert := assert.New(t)
+
+	// Defaults before anything is advertised
+	{
+		i := newISupport()
+		assert.Equal("rfc1459", i.CaseMapping())
+		assert.Equal("#&", i.ChanTypes())
+		modes, prefixes := i.Prefix()
+		assert.Equal("ov", modes)
+		assert.Equal("@+", prefixes)
+		assert.Equal(ChanModes{A: "beI", B: "k", C: "l", D: "imnpst"}, i.ChanModes())
+		assert.Equal(9, i.NickLen())
+		assert.Equal(3, i.Modes())
+		assert.Equal(512, i.LineLen())
+		_, limited := i.TargMax(PRIVMSG)
+		assert.False(limited)
+	}
+
+	// Tokens advertised empty aren't replaced by the defaults
+	{
+		i := newISupport()
+		i.parse([]string{"CHANTYPES=", "CHANMODES="})
+		assert.Equal("", i.ChanTypes())
+		assert.False(i.IsChannel("#chatto"))
+		assert.Equal(ChanModes{}, i.ChanModes())
+		i.parse([]string{"-CHANTYPES"})
+		assert.True(i.IsChannel("#chatto"))
+	}
+
+	// Advertised tokens
+	{
+		i := newISupport()
+		i.parse([]string{
+			"CASEMAPPING=ascii", "CHANTYPES=#", "PREFIX=(qaohv)~&@%+",
+			"CHANMODES=beIq,k,flj,CFLMPQScgimnprstuz", "NICKLEN=30", "MODES=",
+			"TARGMAX=NAMES:1,LIST:1,KICK:1,WHOIS:1,PRIVMSG:4,NOTICE:4,ACCEPT:,MONITOR:",
+			"NETWORK=Chatto\\x20Net", "STATUSMSG=@+", "EXCEPTS", "TOPICLEN=390",
+		})
+		assert.Equal("ascii", i.CaseMapping())
+		assert.True(i.IsChannel("#chatto"))
+		assert.False(i.IsChannel("&chatto"))
+		assert.False(i.IsChannel(""))
+		modes, prefixes := i.Prefix()
+		assert.Equal("qaohv", modes)
+		assert.Equal("~&@%+", prefixes)
+		mode, ok := i.PrefixMode('%')
+		assert.True(ok)
+		assert.Equal(byte('h'), mode)
+		prefix, ok := i.ModePrefix('q')
+		assert.True(ok)
+		assert.Equal(byte('~'), prefix)
+		assert.Equal(ChanModes{A: "beIq", B: "k", C: "flj", D: "CFLMPQScgimnprstuz"}, i.ChanModes())
+		assert.Equal(30, i.NickLen())
+		assert.Equal(0, i.Modes())
+		assert.Equal(390, i.TopicLen())
+		assert.Equal("Chatto Net", i.Network())
+		assert.Equal("@+", i.StatusMsg())
+		assert.True(i.Has("excepts"))
+		n, limited := i.TargMax("privmsg")
+		assert.True(limited)
+		assert.Equal(4, n)
+		_, limited = i.TargMax("MONITOR")
+		assert.False(limited)
+
+		// Negated tokens restore the defaults
+		i.parse([]string{"-CASEMAPPING", "-EXCEPTS"})
+		assert.Equal("rfc1459", i.CaseMapping())
+		assert.False(i.Has("EXCEPTS"))
+	}
+}
+
+func TestISupportRegistration(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto"})
+	err := connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 005 chatto CASEMAPPING=ascii NICKLEN=16 :are supported by this server",
+			":irc.test 005 chatto NETWORK=Chatto PREFIX=(ov)@+ :are supported by this server",
+			":irc.test 422 chatto :MOTD File is missing",
+		)
+	})
+	require.Nil(err)
+	require.Equal("ascii", c.ISupport().CaseMapping())
+	require.Equal(16, c.ISupport().NickLen())
+	require.Equal("Chatto", c.ISupport().Network())
+	require.Nil(closeClient(c, srv))
+}
@@ -0,0 +1,114 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPingInterval = 1 * time.Minute
+	defaultPingTimeout  = 3 * time.Minute
+)
+
+var ErrPingTimeout = errors.New("ping timeout")
+
+type KeepaliveConfig struct {
+	// Interval between client PINGs, defaults to one minute and disabled when negative
+	Interval time.Duration
+	// Timeout after which a silent connection is considered dead, defaults to three minutes
+	Timeout time.Duration
+}
+
+// keepalive tracks the server activity and the round-trip lag of our PINGs.
+type keepalive struct {
+	mu       sync.Mutex
+	lastRecv time.Time
+	token    string
+	sentAt   time.Time
+	lag      time.Duration
+}
+
+func (k *keepalive) reset() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.lastRecv = time.Now()
+	k.token = ""
+	k.lag = 0
+}
+
+func (k *keepalive) received() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.lastRecv = time.Now()
+}
+
+func (k *keepalive) idle() time.Duration {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return time.Since(k.lastRecv)
+}
+
+// ping returns a new token to send unless one is still awaiting its PONG.
+func (k *keepalive) ping() (string, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.token != "" {
+		return "", false
+	}
+	k.sentAt = time.Now()
+	k.token = "chatto-" + strconv.FormatInt(k.sentAt.UnixNano(), 36)
+	return k.token, true
+}
+
+func (k *keepalive) pong(token string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.token == "" || token != k.token {
+		return false
+	}
+	k.lag = time.Since(k.sentAt)
+	k.token = ""
+	return true
+}
+
+// Lag returns the round-trip time measured by the last answered PING.
+func (c *Client) Lag() time.Duration {
+	c.keepalive.mu.Lock()
+	defer c.keepalive.mu.Unlock()
+	return c.keepalive.lag
+}
+
+func (c *Client) runKeepalive(ctx context.Context) {
+	defer c.wg.Done()
+	cfg := c.cfg.Keepalive
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		c.mu.RLock()
+		registered := c.registered
+		c.mu.RUnlock()
+		if !registered {
+			continue
+		}
+		if c.keepalive.idle() > cfg.Timeout {
+			c.handleError(ErrPingTimeout)
+			c.disconnect(ctx, ErrPingTimeout)
+			return
+		}
+		if token, ok := c.keepalive.ping(); ok {
+			if err := c.Ping(ctx, token); err != nil && ctx.Err() == nil {
+				log.Errorf("Error sending PING: %+v", err)
+			}
+		}
+	}
+}
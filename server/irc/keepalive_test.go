@@ -0,0 +1,71 @@
+package irc
+
+import (
+	utesting "chatto/util/testing"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeepalive(t *testing.T) {
+	require := require.New(t)
+	ctx, cancel := utesting.CreateTestingContext()
+	defer cancel()
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{
+		Nick: "chatto",
+		Keepalive: KeepaliveConfig{
+			Interval: 20 * time.Millisecond,
+			Timeout:  200 * time.Millisecond,
+		},
+	})
+	lag := make(chan time.Duration, 1)
+	c.Once(ctx, LAG, func(e Event) {
+		lag <- e.Client.Lag()
+	})
+	disconnected := make(chan error, 1)
+	c.Once(ctx, DISCONNECTED, func(e Event) {
+		disconnected <- e.Error
+	})
+
+	err := connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+
+	// Server PINGs are answered with their token
+	srv.send("PING :irc.test")
+	srv.expect("PONG :irc.test")
+
+	// Client PINGs measure the lag once answered
+	line := srv.readLine()
+	require.True(strings.HasPrefix(line, "PING "))
+	token := strings.TrimPrefix(line, "PING ")
+	time.Sleep(10 * time.Millisecond)
+	srv.send(":irc.test PONG irc.test :" + token)
+	select {
+	case d := <-lag:
+		require.GreaterOrEqual(int64(d), int64(10*time.Millisecond))
+	case <-ctx.Done():
+		require.FailNow("Expected LAG event before timeout")
+	}
+
+	// A silent server eventually times out the connection
+	select {
+	case err := <-disconnected:
+		require.Equal(ErrPingTimeout, err)
+	case <-ctx.Done():
+		require.FailNow("Expected DISCONNECTED event before timeout")
+	}
+	require.False(c.Connected())
+	require.Equal(ErrPingTimeout, c.Err())
+}
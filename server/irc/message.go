@@ -1,15 +1,32 @@
 package irc
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+var (
+	ErrInvalidCommand = errors.New("invalid command")
+	ErrInvalidSource  = errors.New("invalid source")
+	ErrInvalidParam   = errors.New("invalid parameter")
+	ErrInvalidTag     = errors.New("invalid tag")
+	ErrInvalidLine    = errors.New("invalid line")
+)
+
+// forbiddenChars can't appear anywhere in a line
+const forbiddenChars = "\x00\r\n"
+
 type Message struct {
+	Tags                   Tags
 	Nick, Ident, Host, Src string
 	Raw, Cmd               string
 	Args                   []string
 	Time                   time.Time
+	// trailing sends the last parameter as trailing parameter even when not needed
+	trailing bool
 }
 
 func parseLine(s string) (msg Message) {
@@ -20,6 +37,17 @@ func parseLine(s string) (msg Message) {
 	}
 	msg.Raw = s
 
+	if s[0] == '@' {
+		if idx := strings.Index(s, " "); idx != -1 {
+			msg.Tags, s = parseTags(s[1:idx]), strings.TrimLeft(s[idx:], " ")
+		} else {
+			return msg
+		}
+		if s == "" {
+			return msg
+		}
+	}
+
 	if s[0] == ':' {
 		if idx := strings.Index(s, " "); idx != -1 {
 			msg.Src, s = s[1:idx], s[idx:]
@@ -51,3 +79,126 @@ func parseLine(s string) (msg Message) {
 	}
 	return msg
 }
+
+// String returns the message in its wire format without the CRLF, see Encode.
+// Invalid messages are encoded as is.
+func (m Message) String() string {
+	line, _ := m.encode(false)
+	return line
+}
+
+// Encode returns the message in its wire format without the CRLF. The last
+// parameter is sent as trailing parameter when needed, while the source is
+// built from the nick, ident and host when Src is empty. It fails when the
+// message can't be represented on the wire, e.g. when a parameter contains a
+// line break or a parameter other than the last one contains a space.
+func (m Message) Encode() (string, error) {
+	return m.encode(true)
+}
+
+func (m Message) encode(validate bool) (string, error) {
+	if validate {
+		if err := m.validate(); err != nil {
+			return "", err
+		}
+	}
+	var sb strings.Builder
+	if len(m.Tags) > 0 {
+		sb.WriteByte('@')
+		sb.WriteString(m.Tags.String())
+		sb.WriteByte(' ')
+	}
+	if src := m.source(); src != "" {
+		sb.WriteByte(':')
+		sb.WriteString(src)
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(m.Cmd)
+	args := trailingParams(m.Args)
+	if n := len(m.Args); m.trailing && n > 0 {
+		args = append(append([]string{}, m.Args[:n-1]...), ":"+m.Args[n-1])
+	}
+	for _, arg := range args {
+		sb.WriteByte(' ')
+		sb.WriteString(arg)
+	}
+	return sb.String(), nil
+}
+
+func (m Message) source() string {
+	if m.Src != "" || m.Nick == "" {
+		return m.Src
+	}
+	src := m.Nick
+	if m.Ident != "" {
+		src += "!" + m.Ident
+	}
+	if m.Host != "" {
+		src += "@" + m.Host
+	}
+	return src
+}
+
+func (m Message) validate() error {
+	if !validCommand(m.Cmd) {
+		return fmt.Errorf("%w: %q", ErrInvalidCommand, m.Cmd)
+	}
+	if src := m.source(); strings.ContainsAny(src, forbiddenChars+" ") {
+		return fmt.Errorf("%w: %q", ErrInvalidSource, src)
+	}
+	for key, value := range m.Tags {
+		if !validTagKey(key) {
+			return fmt.Errorf("%w: %q", ErrInvalidTag, key)
+		}
+		// The other special characters are escaped
+		if strings.Contains(value, "\x00") || !utf8.ValidString(value) {
+			return fmt.Errorf("%w %s value: %q", ErrInvalidTag, key, value)
+		}
+	}
+	for i, arg := range m.Args {
+		invalid := strings.ContainsAny(arg, forbiddenChars)
+		// Only the last parameter can be empty, contain spaces or start with a colon
+		if i < len(m.Args)-1 {
+			invalid = invalid || arg == "" || arg[0] == ':' || strings.Contains(arg, " ")
+		}
+		if invalid {
+			return fmt.Errorf("%w %d: %q", ErrInvalidParam, i+1, arg)
+		}
+	}
+	return nil
+}
+
+// validCommand reports whether the command is made of letters or is a
+// three-digit numeric.
+func validCommand(cmd string) bool {
+	if cmd == "" {
+		return false
+	}
+	if isNumeric(cmd) {
+		return true
+	}
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		if (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// validTagKey reports whether the key is like "[+][vendor/]name".
+func validTagKey(key string) bool {
+	key = strings.TrimPrefix(key, "+")
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		valid := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '/'
+		if !valid {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package irc
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,3 +51,117 @@ func TestMessage(t *testing.T) {
 		assert.Equal("Ping timeout: 2m30s", msg.Args[0])
 	}
 }
+
+func TestMessageTags(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Parse tagged PRIVMSG message
+	{
+		line := `@time=2020-10-10T10:10:10.000Z;msgid=abc;+draft/reply=x\sy\:z\\;account :chatto!~chatto-irc@9qt4sazudxvsk.irc PRIVMSG #chatto :Hello, world!`
+		msg := parseLine(line)
+		assert.Equal(line, msg.Raw)
+		require.NotNil(msg.Tags)
+		assert.Equal("2020-10-10T10:10:10.000Z", msg.Tags["time"])
+		assert.Equal("abc", msg.Tags["msgid"])
+		assert.Equal("x y;z\\", msg.Tags["+draft/reply"])
+		assert.True(msg.Tags.Has("account"))
+		assert.Empty(msg.Tags["account"])
+		assert.Equal("chatto", msg.Nick)
+		assert.Equal("PRIVMSG", msg.Cmd)
+		require.Len(msg.Args, 2)
+		assert.Equal("#chatto", msg.Args[0])
+		assert.Equal("Hello, world!", msg.Args[1])
+	}
+
+	// Parse tagged message without prefix
+	{
+		msg := parseLine(`@a=b\nc\ PING :token`)
+		assert.Equal("b\nc", msg.Tags["a"])
+		assert.Empty(msg.Src)
+		assert.Equal("PING", msg.Cmd)
+		require.Len(msg.Args, 1)
+		assert.Equal("token", msg.Args[0])
+	}
+
+	// Serialize tags with escaping
+	{
+		tags := Tags{
+			"b":            "",
+			"a":            "x y;z\\\r\n",
+			"+draft/reply": "abc",
+		}
+		s := tags.String()
+		assert.Equal("+draft/reply=abc;a=x\\sy\\:z\\\\\\r\\n;b", s)
+		assert.Equal(tags, parseTags(s))
+	}
+}
+
+func TestMessageEncode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Encoded lines parse back to the same message
+	messages := []Message{
+		{Cmd: "PING", Args: []string{"token"}},
+		{Cmd: "PRIVMSG", Args: []string{"#chatto", "Hello, world!"}},
+		{Cmd: "PRIVMSG", Args: []string{"#chatto", ":)"}},
+		{Cmd: "TOPIC", Args: []string{"#chatto", ""}},
+		{Cmd: "AWAY"},
+		{Src: "irc.test", Cmd: RPL_WELCOME, Args: []string{"chatto", "Welcome"}},
+		{Nick: "chatto", Ident: "~chatto-irc", Host: "chatto.host", Cmd: "JOIN", Args: []string{"#chatto"}},
+		{Tags: Tags{"+draft/reply": "x y;z\\", "account": ""}, Cmd: "TAGMSG", Args: []string{"#chatto"}},
+	}
+	for _, msg := range messages {
+		line, err := msg.Encode()
+		require.Nil(err)
+		parsed := parseLine(line)
+		assert.Equal(msg.Cmd, parsed.Cmd, line)
+		assert.Equal(msg.source(), parsed.Src, line)
+		assert.Equal(len(msg.Args), len(parsed.Args), line)
+		for i := range msg.Args {
+			assert.Equal(msg.Args[i], parsed.Args[i], line)
+		}
+		assert.Equal(len(msg.Tags), len(parsed.Tags), line)
+		for key, value := range msg.Tags {
+			assert.Equal(value, parsed.Tags[key], line)
+		}
+	}
+
+	// Parsed lines encode back to the same line
+	lines := []string{
+		"@msgid=abc;time=2020-10-10T10:10:10.000Z :chatto!~chatto-irc@chatto.host PRIVMSG #chatto :Hello, world!",
+		":irc.test 005 chatto CHANTYPES=# PREFIX=(ov)@+ :are supported by this server",
+		"CAP REQ :multi-prefix server-time",
+		"MODE #chatto +o alice",
+		"PRIVMSG #chatto ::)",
+	}
+	for _, line := range lines {
+		assert.Equal(line, parseLine(line).String())
+	}
+	assert.Equal("PRIVMSG #chatto Hi", Message{Cmd: "PRIVMSG", Args: []string{"#chatto", "Hi"}}.String())
+
+	// Messages which can't be represented on the wire
+	invalid := map[error]Message{
+		ErrInvalidCommand: {Cmd: "PRIV MSG"},
+		ErrInvalidSource:  {Src: "irc test", Cmd: "PING"},
+		ErrInvalidTag:     {Tags: Tags{"bad key": "x"}, Cmd: "TAGMSG", Args: []string{"#chatto"}},
+	}
+	for expected, msg := range invalid {
+		_, err := msg.Encode()
+		assert.True(errors.Is(err, expected), msg)
+	}
+	_, err := Message{Tags: Tags{"+draft/reply": "a\x00b"}, Cmd: "TAGMSG", Args: []string{"#chatto"}}.Encode()
+	assert.True(errors.Is(err, ErrInvalidTag))
+	params := [][]string{
+		{"#chatto", "Hello\r\nQUIT"},
+		{"#chatto\x00", "Hi"},
+		{"#chatto #go", "Hi"},
+		{":#chatto", "Hi"},
+		{"", "Hi"},
+	}
+	for _, args := range params {
+		_, err := Message{Cmd: "PRIVMSG", Args: args}.Encode()
+		assert.True(errors.Is(err, ErrInvalidParam), args)
+	}
+}
@@ -0,0 +1,198 @@
+package irc
+
+import (
+	"context"
+	"strings"
+)
+
+// Events emitted for the most common channel mode changes, along with the
+// CHANNEL_MODE and USER_MODE events holding all the changes of a MODE message.
+var modeEvents = map[byte][2]string{
+	'o': {DEOP, OP},
+	'v': {DEVOICE, VOICE},
+	'b': {UNBAN, BAN},
+}
+
+// ModeChange is a single mode set or unset by a MODE message.
+type ModeChange struct {
+	Add  bool
+	Mode byte
+	// Param is the nick, mask or value of the mode, if it takes one
+	Param string
+}
+
+func (m ModeChange) String() string {
+	s := "-" + string(m.Mode)
+	if m.Add {
+		s = "+" + string(m.Mode)
+	}
+	if m.Param != "" {
+		s += " " + m.Param
+	}
+	return s
+}
+
+// ParseModeChanges parses the mode string and its parameters of a channel MODE,
+// using the advertised CHANMODES and PREFIX to know which modes take a parameter.
+func (i *ISupport) ParseModeChanges(args []string) []ModeChange {
+	if len(args) < 1 {
+		return nil
+	}
+	chanModes := i.ChanModes()
+	prefixModes, _ := i.Prefix()
+	return parseModeChanges(args[0], args[1:], func(add bool, mode byte) bool {
+		switch {
+		case strings.IndexByte(prefixModes, mode) != -1,
+			strings.IndexByte(chanModes.A, mode) != -1,
+			strings.IndexByte(chanModes.B, mode) != -1:
+			return true
+		case strings.IndexByte(chanModes.C, mode) != -1:
+			return add
+		}
+		return false
+	})
+}
+
+// ParseUserModeChanges parses the mode string of a user MODE, whose modes
+// never take a parameter.
+func ParseUserModeChanges(modes string) []ModeChange {
+	return parseModeChanges(modes, nil, func(bool, byte) bool {
+		return false
+	})
+}
+
+func parseModeChanges(modes string, params []string, hasParam func(add bool, mode byte) bool) []ModeChange {
+	changes := make([]ModeChange, 0, len(modes))
+	add := true
+	for i := 0; i < len(modes); i++ {
+		mode := modes[i]
+		switch mode {
+		case '+':
+			add = true
+			continue
+		case '-':
+			add = false
+			continue
+		}
+		change := ModeChange{Add: add, Mode: mode}
+		if hasParam(add, mode) && len(params) > 0 {
+			change.Param, params = params[0], params[1:]
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// FormatModeChanges returns the mode string and parameters of the changes,
+// like "+o-v" with "alice" and "bob".
+func FormatModeChanges(changes []ModeChange) (string, []string) {
+	var sb strings.Builder
+	params := make([]string, 0, len(changes))
+	sign := byte(0)
+	for _, change := range changes {
+		next := byte('-')
+		if change.Add {
+			next = '+'
+		}
+		if next != sign {
+			sb.WriteByte(next)
+			sign = next
+		}
+		sb.WriteByte(change.Mode)
+		if change.Param != "" {
+			params = append(params, change.Param)
+		}
+	}
+	return sb.String(), params
+}
+
+// Mode sends a raw MODE for the target, which queries its modes when no
+// modes are given.
+func (c *Commands) Mode(ctx context.Context, target string, modes ...string) error {
+	return c.Command(ctx, MODE, append([]string{target}, modes...)...)
+}
+
+// ChangeModes applies the changes to the target, split in as many MODE
+// commands as needed to honour the MODES limit of the server.
+func (c *Client) ChangeModes(ctx context.Context, target string, changes ...ModeChange) error {
+	limit := c.isupport.Modes()
+	for len(changes) > 0 {
+		n, params := 0, 0
+		for n < len(changes) {
+			if changes[n].Param != "" {
+				if limit > 0 && params >= limit {
+					break
+				}
+				params++
+			}
+			n++
+		}
+		modes, args := FormatModeChanges(changes[:n])
+		if err := c.Mode(ctx, target, append([]string{modes}, args...)...); err != nil {
+			return err
+		}
+		changes = changes[n:]
+	}
+	return nil
+}
+
+func (c *Client) Op(ctx context.Context, channel string, nicks ...string) error {
+	return c.ChangeModes(ctx, channel, modeChanges(true, 'o', nicks)...)
+}
+
+func (c *Client) Deop(ctx context.Context, channel string, nicks ...string) error {
+	return c.ChangeModes(ctx, channel, modeChanges(false, 'o', nicks)...)
+}
+
+func (c *Client) Voice(ctx context.Context, channel string, nicks ...string) error {
+	return c.ChangeModes(ctx, channel, modeChanges(true, 'v', nicks)...)
+}
+
+func (c *Client) Devoice(ctx context.Context, channel string, nicks ...string) error {
+	return c.ChangeModes(ctx, channel, modeChanges(false, 'v', nicks)...)
+}
+
+func (c *Client) Ban(ctx context.Context, channel string, masks ...string) error {
+	return c.ChangeModes(ctx, channel, modeChanges(true, 'b', masks)...)
+}
+
+func (c *Client) Unban(ctx context.Context, channel string, masks ...string) error {
+	return c.ChangeModes(ctx, channel, modeChanges(false, 'b', masks)...)
+}
+
+func modeChanges(add bool, mode byte, params []string) []ModeChange {
+	changes := make([]ModeChange, len(params))
+	for i, param := range params {
+		changes[i] = ModeChange{Add: add, Mode: mode, Param: param}
+	}
+	return changes
+}
+
+// UserModes returns the modes set on our own user, like "iw".
+func (c *Client) UserModes() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.userModes
+}
+
+func (c *Client) HasUserMode(mode byte) bool {
+	return strings.IndexByte(c.UserModes(), mode) != -1
+}
+
+// applyUserModes applies the changes to our user modes, resetting them first
+// when the changes come from RPL_UMODEIS.
+func (c *Client) applyUserModes(changes []ModeChange, reset bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if reset {
+		c.userModes = ""
+	}
+	for _, change := range changes {
+		has := strings.IndexByte(c.userModes, change.Mode) != -1
+		if change.Add && !has {
+			c.userModes += string(change.Mode)
+		} else if !change.Add && has {
+			c.userModes = strings.Replace(c.userModes, string(change.Mode), "", 1)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package irc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModeChanges(t *testing.T) {
+	assert := assert.New(t)
+	i := newISupport()
+
+	changes := i.ParseModeChanges([]string{"+ntkl-o+b", "key", "10", "alice", "*!*@bad.host"})
+	assert.Equal([]ModeChange{
+		{Add: true, Mode: 'n'},
+		{Add: true, Mode: 't'},
+		{Add: true, Mode: 'k', Param: "key"},
+		{Add: true, Mode: 'l', Param: "10"},
+		{Add: false, Mode: 'o', Param: "alice"},
+		{Add: true, Mode: 'b', Param: "*!*@bad.host"},
+	}, changes)
+
+	// The limit takes no parameter when removed
+	changes = i.ParseModeChanges([]string{"-lk", "key"})
+	assert.Equal([]ModeChange{
+		{Add: false, Mode: 'l'},
+		{Add: false, Mode: 'k', Param: "key"},
+	}, changes)
+}
+
+func TestUserModeChanges(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]ModeChange{
+		{Add: true, Mode: 'i'},
+		{Add: true, Mode: 'w'},
+		{Add: false, Mode: 'x'},
+	}, ParseUserModeChanges("+iw-x"))
+}
+
+func TestFormatModeChanges(t *testing.T) {
+	assert := assert.New(t)
+	modes, params := FormatModeChanges([]ModeChange{
+		{Add: true, Mode: 'o', Param: "alice"},
+		{Add: true, Mode: 'n'},
+		{Add: false, Mode: 'v', Param: "bob"},
+		{Add: false, Mode: 'l'},
+	})
+	assert.Equal("+on-vl", modes)
+	assert.Equal([]string{"alice", "bob"}, params)
+	assert.Equal("+o alice", ModeChange{Add: true, Mode: 'o', Param: "alice"}.String())
+	assert.Equal("-m", ModeChange{Mode: 'm'}.String())
+}
+
+func TestModes(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto"})
+	err := connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 005 chatto MODES=2 :are supported by this server",
+			":irc.test 376 chatto :End of /MOTD command.",
+			":irc.test 221 chatto +i",
+		)
+	})
+	require.Nil(err)
+
+	// Changes are split according to the MODES limit
+	ctx := context.Background()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Op(ctx, "#chatto", "alice", "bob", "carol")
+	}()
+	srv.expect("MODE #chatto +oo alice bob")
+	srv.expect("MODE #chatto +o carol")
+	require.Nil(<-errs)
+	go func() {
+		errs <- c.ChangeModes(ctx, "#chatto", ModeChange{Add: true, Mode: 'm'}, ModeChange{Mode: 'b', Param: "*!*@host"})
+	}()
+	srv.expect("MODE #chatto +m-b *!*@host")
+	require.Nil(<-errs)
+	go func() {
+		errs <- c.Mode(ctx, "#chatto")
+	}()
+	srv.expect("MODE #chatto")
+	require.Nil(<-errs)
+
+	events := make(map[string]chan Event)
+	for _, name := range []string{CHANNEL_MODE, OP, DEVOICE, BAN, USER_MODE} {
+		ch := make(chan Event, 1)
+		events[name] = ch
+		c.Each(ctx, name, func(e Event) {
+			ch <- e
+		})
+	}
+	srv.send(
+		":chatto!~chatto@chatto.host JOIN #chatto",
+		":irc.test 353 chatto = #chatto :@chatto +alice",
+		":irc.test 366 chatto #chatto :End of /NAMES list.",
+		":chatto!~chatto@chatto.host MODE #chatto +o-v+b alice alice *!*@bad.host",
+		":chatto MODE chatto :+w",
+	)
+	received := func(name string) Event {
+		select {
+		case e := <-events[name]:
+			return e
+		case <-time.After(time.Second):
+			require.Fail(name + " event timed out")
+		}
+		return Event{}
+	}
+	require.Len(received(CHANNEL_MODE).Modes, 3)
+	op := received(OP)
+	require.Equal("#chatto", op.Channel)
+	require.Equal([]ModeChange{{Add: true, Mode: 'o', Param: "alice"}}, op.Modes)
+	require.Equal([]ModeChange{{Add: false, Mode: 'v', Param: "alice"}}, received(DEVOICE).Modes)
+	require.Equal("*!*@bad.host", received(BAN).Modes[0].Param)
+	require.Equal([]ModeChange{{Add: true, Mode: 'w'}}, received(USER_MODE).Modes)
+
+	ch := c.Channel("#chatto")
+	require.True(ch.IsOp("alice"))
+	require.False(ch.HasMode("alice", 'v'))
+	require.Equal([]string{"*!*@bad.host"}, ch.List('b'))
+	require.Equal("iw", c.UserModes())
+	require.True(c.HasUserMode('w'))
+
+	require.Nil(closeClient(c, srv))
+}
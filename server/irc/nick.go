@@ -0,0 +1,137 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxNickAttempts = 10
+	defaultReclaimInterval = 1 * time.Minute
+)
+
+var ErrNickUnavailable = errors.New("no nick available")
+
+type NickConfig struct {
+	// Strategy picks the nicks tried when the server rejects ours during
+	// registration, defaults to appending underscores
+	Strategy NickStrategy
+	// MaxAttempts limits the nicks tried after the configured one, defaults to 10
+	MaxAttempts int
+	// Reclaim takes the configured nick back once it frees up when registered
+	// with another one
+	Reclaim bool
+	// ReclaimInterval between the ISON checks when the server doesn't support
+	// MONITOR, defaults to one minute
+	ReclaimInterval time.Duration
+}
+
+// NickStrategy picks the nicks to try when the preferred one is unavailable.
+type NickStrategy interface {
+	// Next returns the nick for the given attempt, starting at 1, or false when
+	// there's none left. nickLen is the NICKLEN advertised by the server, or
+	// zero when unknown.
+	Next(nick string, attempt int, nickLen int) (string, bool)
+}
+
+type NickStrategyFunc func(nick string, attempt int, nickLen int) (string, bool)
+
+func (f NickStrategyFunc) Next(nick string, attempt int, nickLen int) (string, bool) {
+	return f(nick, attempt, nickLen)
+}
+
+// UnderscoreNicks appends underscores to the nick: chatto_, chatto__, ...
+var UnderscoreNicks NickStrategy = NickStrategyFunc(func(nick string, attempt int, nickLen int) (string, bool) {
+	return truncateNick(nick, strings.Repeat("_", attempt), nickLen), true
+})
+
+// DigitNicks appends increasing numbers to the nick: chatto1, chatto2, ...
+var DigitNicks NickStrategy = NickStrategyFunc(func(nick string, attempt int, nickLen int) (string, bool) {
+	return truncateNick(nick, strconv.Itoa(attempt), nickLen), true
+})
+
+// AlternateNicks tries the nicks in order, then the ones of the fallback
+// strategy unless it is nil.
+func AlternateNicks(fallback NickStrategy, nicks ...string) NickStrategy {
+	return NickStrategyFunc(func(nick string, attempt int, nickLen int) (string, bool) {
+		if attempt <= len(nicks) {
+			return truncateNick(nicks[attempt-1], "", nickLen), true
+		}
+		if fallback == nil {
+			return "", false
+		}
+		return fallback.Next(nick, attempt-len(nicks), nickLen)
+	})
+}
+
+// truncateNick appends the suffix to the nick, shortening the nick so the
+// result fits in nickLen while keeping at least its first character.
+func truncateNick(nick string, suffix string, nickLen int) string {
+	if keep := nickLen - len(suffix); nickLen > 0 && keep < len(nick) {
+		if keep < 1 {
+			keep = 1
+		}
+		nick = nick[:keep]
+	}
+	return nick + suffix
+}
+
+// reclaimNick takes the preferred nick back once it frees up, watching it with
+// MONITOR when the server supports it and polling with ISON otherwise.
+func (c *Client) reclaimNick(ctx context.Context) {
+	nick := c.preferredNick()
+	freed := make(chan struct{}, 1)
+	var poll <-chan time.Time
+	monitor := c.isupport.Has(MONITOR)
+	if monitor {
+		obs := c.Each(ctx, RPL_MONOFFLINE, func(e Event) {
+			args := e.Message.Args
+			if len(args) > 1 && c.containsNick(strings.Split(args[len(args)-1], ","), nick) {
+				select {
+				case freed <- struct{}{}:
+				default:
+				}
+			}
+		})
+		defer obs.Remove()
+		if err := c.Command(ctx, MONITOR, "+", nick); err != nil {
+			return
+		}
+	} else {
+		ticker := time.NewTicker(c.cfg.Nicks.ReclaimInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	// Stop as well once the nick gets changed on purpose
+	for c.isupport.EqualFold(c.preferredNick(), nick) && !c.isSelf(nick) {
+		select {
+		case <-freed:
+		case <-poll:
+			online, err := c.IsonWait(ctx, nick)
+			if err != nil || c.containsNick(online, nick) {
+				continue
+			}
+		case <-ctx.Done():
+			return
+		}
+		if _, err := c.CommandWait(ctx, NICK, nick); err == nil {
+			break
+		}
+	}
+	if monitor {
+		_ = c.Command(ctx, MONITOR, "-", nick)
+	}
+}
+
+func (c *Client) containsNick(nicks []string, nick string) bool {
+	for _, n := range nicks {
+		if c.isupport.EqualFold(n, nick) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNickStrategies(t *testing.T) {
+	assert := assert.New(t)
+	next := func(s NickStrategy, attempt int, nickLen int) string {
+		nick, ok := s.Next("chatto", attempt, nickLen)
+		assert.True(ok)
+		return nick
+	}
+	assert.Equal("chatto_", next(UnderscoreNicks, 1, 0))
+	assert.Equal("chatto__", next(UnderscoreNicks, 2, 0))
+	assert.Equal("chatt__", next(UnderscoreNicks, 2, 7))
+	assert.Equal("chatto1", next(DigitNicks, 1, 0))
+	assert.Equal("chat12", next(DigitNicks, 12, 6))
+	assert.Equal("c123", next(DigitNicks, 123, 3))
+
+	alternates := AlternateNicks(DigitNicks, "chattobot", "chatto_irc")
+	assert.Equal("chattobot", next(alternates, 1, 0))
+	assert.Equal("chatto_i", next(alternates, 2, 8))
+	assert.Equal("chatto1", next(alternates, 3, 0))
+	_, ok := AlternateNicks(nil, "chattobot").Next("chatto", 2, 0)
+	assert.False(ok)
+}
+
+func TestNickCollision(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{
+		Nick:  "chatto",
+		Nicks: NickConfig{Strategy: AlternateNicks(DigitNicks, "chattobot")},
+	})
+	err := connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(":irc.test 433 * chatto :Nickname is already in use")
+		srv.expect("NICK chattobot")
+		srv.send(":irc.test 432 * chattobot :Erroneous nickname")
+		srv.expect("NICK chatto1")
+		srv.send(
+			// Replies about a replaced nick are ignored
+			":irc.test 433 * chatto :Nickname is already in use",
+			":irc.test 437 * chatto1 :Nick/channel is temporarily unavailable",
+		)
+		srv.expect("NICK chatto2")
+		srv.send(":irc.test 436 * chatto2 :Nickname collision KILL")
+		srv.expect("NICK chatto3")
+		srv.send(
+			":irc.test 001 chatto3 :Welcome",
+			":irc.test 376 chatto3 :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+	require.Equal("chatto3", c.CurrentNick())
+	require.Nil(closeClient(c, srv))
+
+	// Giving up once the attempts are exhausted
+	srv, conn = newTestServer(require)
+	defer srv.close()
+	c = NewClient(Config{Nick: "chatto", Nicks: NickConfig{MaxAttempts: 2}})
+	err = connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(":irc.test 433 * chatto :Nickname is already in use")
+		srv.expect("NICK chatto_")
+		srv.send(":irc.test 433 * chatto_ :Nickname is already in use")
+		srv.expect("NICK chatto__")
+		srv.send(":irc.test 433 * chatto__ :Nickname is already in use")
+	})
+	require.True(errors.Is(err, ErrNickUnavailable))
+	require.Contains(err.Error(), "chatto, chatto_, chatto__")
+}
+
+func TestReclaimNick(t *testing.T) {
+	require := require.New(t)
+
+	connect := func(isupport string) (*Client, *testServer) {
+		srv, conn := newTestServer(require)
+		c := NewClient(Config{
+			Nick:  "chatto",
+			Flood: FloodConfig{Burst: -1},
+			Nicks: NickConfig{Reclaim: true, ReclaimInterval: 10 * time.Millisecond},
+		})
+		err := connectClient(c, conn, func() {
+			srv.expect("NICK chatto")
+			srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+			srv.send(":irc.test 433 * chatto :Nickname is already in use")
+			srv.expect("NICK chatto_")
+			srv.send(
+				":irc.test 001 chatto_ :Welcome",
+				":irc.test 005 chatto_ "+isupport+" :are supported by this server",
+				":irc.test 376 chatto_ :End of /MOTD command.",
+			)
+		})
+		require.Nil(err)
+		return c, srv
+	}
+
+	// Polling with ISON
+	c, srv := connect("NETWORK=Test")
+	defer srv.close()
+	srv.expect("ISON chatto")
+	srv.send(":irc.test 303 chatto_ :chatto")
+	srv.expect("ISON chatto")
+	srv.send(":irc.test 303 chatto_ :")
+	srv.expect("NICK chatto")
+	srv.send(":chatto_!~chatto@chatto.host NICK chatto")
+	require.Eventually(func() bool {
+		return c.CurrentNick() == "chatto"
+	}, time.Second, 10*time.Millisecond)
+	require.Nil(closeClient(c, srv))
+
+	// Watching with MONITOR
+	c, srv = connect("MONITOR=100")
+	defer srv.close()
+	srv.expect("MONITOR + chatto")
+	srv.send(
+		":irc.test 730 chatto_ :chatto!~chatto@other.host",
+		":irc.test 731 chatto_ :chatto",
+	)
+	srv.expect("NICK chatto")
+	srv.send(":chatto_!~chatto@chatto.host NICK chatto")
+	srv.expect("MONITOR - chatto")
+	require.Eventually(func() bool {
+		return c.CurrentNick() == "chatto"
+	}, time.Second, 10*time.Millisecond)
+	require.Nil(closeClient(c, srv))
+}
+
+func TestNickChange(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	// Our own nick changes become the preferred nick
+	ctx := context.Background()
+	require.Nil(c.Nick(ctx, "chattobot"))
+	srv.expect("NICK chattobot")
+	srv.send(":chatto!~chatto@chatto.host NICK chattobot")
+	require.Eventually(func() bool {
+		return c.CurrentNick() == "chattobot"
+	}, time.Second, 10*time.Millisecond)
+	require.Equal("chattobot", c.preferredNick())
+
+	// unlike the ones forced by the server
+	srv.send(":chattobot!~chatto@chatto.host NICK Guest42")
+	require.Eventually(func() bool {
+		return c.CurrentNick() == "Guest42"
+	}, time.Second, 10*time.Millisecond)
+	require.Equal("chattobot", c.preferredNick())
+	require.Nil(closeClient(c, srv))
+}
@@ -0,0 +1,216 @@
+package irc
+
+import (
+	"context"
+	"strings"
+)
+
+// query describes the numeric replies of a command up to the one ending them.
+type query struct {
+	// replies lists the numerics to collect, every numeric about the target
+	// is collected when empty
+	replies []string
+	end     []string
+	// target must be the parameter the replies are about when set, see queryTarget
+	target string
+}
+
+// queryFailures are the numerics failing a query, which mention its command
+var queryFailures = []string{ERR_UNKNOWNCOMMAND, ERR_NEEDMOREPARAMS, RPL_TRYAGAIN}
+
+func (q query) collects(msg Message) bool {
+	if len(q.replies) > 0 {
+		return containsString(q.replies, msg.Cmd)
+	}
+	return isNumeric(msg.Cmd)
+}
+
+// collect sends the command and returns the replies collected until the end
+// numeric is received, along with the end numeric itself. It fails with a
+// *ReplyError when the server rejects the command instead.
+func (c *Commands) collect(ctx context.Context, q query, cmd string, params ...string) ([]Message, Message, error) {
+	msgs, stop := c.observeReplies(ctx)
+	defer stop()
+
+	if err := c.CommandParams(ctx, cmd, params...); err != nil {
+		return nil, Message{}, err
+	}
+	replies := make([]Message, 0)
+	done := c.connDone()
+	for {
+		select {
+		case <-done:
+			return nil, Message{}, ErrNotConnected
+		case msg := <-msgs:
+			if failsQuery(msg, cmd, params) {
+				return nil, Message{}, replyError(cmd, msg)
+			}
+			if target, ok := queryTarget(msg); q.target != "" && !(ok && c.equalFold(target, q.target)) {
+				continue
+			}
+			if containsString(q.end, msg.Cmd) {
+				return replies, msg, nil
+			}
+			if q.collects(msg) {
+				replies = append(replies, msg)
+			}
+		case <-ctx.Done():
+			return nil, Message{}, ctx.Err()
+		}
+	}
+}
+
+// NamesWait returns the members of the channel with their membership prefixes.
+func (c *Commands) NamesWait(ctx context.Context, channel string) ([]string, error) {
+	q := query{replies: []string{RPL_NAMREPLY}, end: []string{RPL_ENDOFNAMES}, target: channel}
+	replies, _, err := c.collect(ctx, q, NAMES, channel)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, msg := range replies {
+		names = append(names, lastFields(msg)...)
+	}
+	return names, nil
+}
+
+// ListWait returns the entries listed for the channels, or for all the
+// channels when none is given.
+func (c *Commands) ListWait(ctx context.Context, channels ...string) ([]ListEntry, error) {
+	q := query{replies: []string{RPL_LIST}, end: []string{RPL_LISTEND}}
+	replies, _, err := c.collect(ctx, q, LIST, joinTargets(channels)...)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]ListEntry, 0, len(replies))
+	for _, msg := range replies {
+		if entry, ok := ParseListEntry(msg); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// WhoWait returns the RPL_WHOREPLY replies for the mask.
+func (c *Commands) WhoWait(ctx context.Context, mask string) ([]Message, error) {
+	q := query{replies: []string{RPL_WHOREPLY}, end: []string{RPL_ENDOFWHO}}
+	replies, _, err := c.collect(ctx, q, WHO, mask)
+	return replies, err
+}
+
+// WhoisWait returns the information about the nick, failing with a
+// *ReplyError when there's no such nick.
+func (c *Commands) WhoisWait(ctx context.Context, nick string) (WhoisReply, error) {
+	q := query{end: []string{RPL_ENDOFWHOIS}, target: nick}
+	replies, _, err := c.collect(ctx, q, WHOIS, nick)
+	if err != nil {
+		return WhoisReply{}, err
+	}
+	for _, msg := range replies {
+		if msg.Cmd == ERR_NOSUCHNICK {
+			return WhoisReply{}, replyError(WHOIS, msg)
+		}
+	}
+	return ParseWhois(replies), nil
+}
+
+// WhowasWait returns all the numerics about the nick up to RPL_ENDOFWHOWAS.
+func (c *Commands) WhowasWait(ctx context.Context, nick string) ([]Message, error) {
+	q := query{end: []string{RPL_ENDOFWHOWAS}, target: nick}
+	replies, _, err := c.collect(ctx, q, WHOWAS, nick)
+	return replies, err
+}
+
+// MotdWait returns the lines of the message of the day, which are empty when
+// the server has none.
+func (c *Commands) MotdWait(ctx context.Context, server ...string) ([]string, error) {
+	q := query{replies: []string{RPL_MOTD}, end: []string{RPL_ENDOFMOTD, ERR_NOMOTD}}
+	replies, _, err := c.collect(ctx, q, MOTD, server...)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(replies))
+	for _, msg := range replies {
+		if len(msg.Args) > 1 {
+			lines = append(lines, msg.Args[len(msg.Args)-1])
+		}
+	}
+	return lines, nil
+}
+
+// UserhostWait returns the replies like "nick*=+user@host" for the nicks
+// which are online.
+func (c *Commands) UserhostWait(ctx context.Context, nicks ...string) ([]string, error) {
+	c.unlabeled.Lock()
+	defer c.unlabeled.Unlock()
+	_, end, err := c.collect(ctx, query{end: []string{RPL_USERHOST}}, USERHOST, nicks...)
+	if err != nil {
+		return nil, err
+	}
+	return lastFields(end), nil
+}
+
+// IsonWait returns the nicks which are online.
+func (c *Commands) IsonWait(ctx context.Context, nicks ...string) ([]string, error) {
+	c.unlabeled.Lock()
+	defer c.unlabeled.Unlock()
+	_, end, err := c.collect(ctx, query{end: []string{RPL_ISON}}, ISON, nicks...)
+	if err != nil {
+		return nil, err
+	}
+	return lastFields(end), nil
+}
+
+// failsQuery reports whether the message rejects the command with the params.
+func failsQuery(msg Message, cmd string, params []string) bool {
+	if len(msg.Args) < 2 {
+		return false
+	}
+	switch {
+	case msg.Cmd == FAIL:
+		return strings.EqualFold(msg.Args[0], cmd)
+	case containsString(queryFailures, msg.Cmd):
+		return strings.EqualFold(msg.Args[1], cmd)
+	case msg.Cmd == ERR_NOSUCHSERVER:
+		// "<client> <server> :No such server" about the server we asked
+		for _, param := range params {
+			if strings.EqualFold(param, msg.Args[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// queryTarget returns the parameter the reply is about, which follows our nick
+// except in RPL_NAMREPLY where the channel comes after its symbol.
+func queryTarget(msg Message) (string, bool) {
+	i := 1
+	if msg.Cmd == RPL_NAMREPLY {
+		// "<client> <symbol> <channel> :<names>"
+		i = len(msg.Args) - 2
+	}
+	if i < 1 || len(msg.Args) <= i {
+		return "", false
+	}
+	return msg.Args[i], true
+}
+
+func lastFields(msg Message) []string {
+	if len(msg.Args) < 2 {
+		return []string{}
+	}
+	return strings.Fields(msg.Args[len(msg.Args)-1])
+}
+
+func isNumeric(cmd string) bool {
+	if len(cmd) != 3 {
+		return false
+	}
+	for i := 0; i < len(cmd); i++ {
+		if cmd[i] < '0' || cmd[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMinDelay     = 1 * time.Second
+	defaultMaxDelay     = 2 * time.Minute
+	defaultJitter       = 0.2
+	defaultCloseTimeout = 5 * time.Second
+	defaultJoinTimeout  = 10 * time.Second
+)
+
+var ErrTooManyAttempts = errors.New("too many connection attempts")
+
+type ReconnectConfig struct {
+	// MinDelay and MaxDelay bound the exponential delay between attempts
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// Jitter is the fraction of the delay randomized on each attempt
+	Jitter float64
+	// MaxAttempts limits the consecutive failed attempts, zero means unlimited
+	MaxAttempts int
+	// CloseTimeout bounds the graceful close once Run is cancelled
+	CloseTimeout time.Duration
+}
+
+type backoff struct {
+	cfg     ReconnectConfig
+	attempt int
+}
+
+func newBackoff(cfg ReconnectConfig) *backoff {
+	if cfg.MinDelay <= 0 {
+		cfg.MinDelay = defaultMinDelay
+	}
+	if cfg.MaxDelay < cfg.MinDelay {
+		cfg.MaxDelay = defaultMaxDelay
+		if cfg.MaxDelay < cfg.MinDelay {
+			cfg.MaxDelay = cfg.MinDelay
+		}
+	}
+	if cfg.Jitter <= 0 || cfg.Jitter > 1 {
+		cfg.Jitter = defaultJitter
+	}
+	return &backoff{cfg: cfg}
+}
+
+// next returns the delay before the next attempt.
+func (b *backoff) next() time.Duration {
+	delay := b.cfg.MinDelay
+	for i := 0; i < b.attempt && delay < b.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > b.cfg.MaxDelay {
+		delay = b.cfg.MaxDelay
+	}
+	b.attempt++
+	jitter := (rand.Float64()*2 - 1) * b.cfg.Jitter
+	return time.Duration(float64(delay) * (1 + jitter))
+}
+
+func (b *backoff) exhausted() bool {
+	return b.cfg.MaxAttempts > 0 && b.attempt >= b.cfg.MaxAttempts
+}
+
+func (b *backoff) reset() {
+	b.attempt = 0
+}
+
+// Run keeps the connection to the server alive until the context is cancelled,
+// reconnecting with a jittered exponential backoff whenever it gets lost and
+// restoring the nick and joined channels afterwards.
+func (c *Conn) Run(ctx context.Context, addr string) error {
+	cfg := c.cfg.Reconnect
+	b := newBackoff(cfg)
+	restore := false
+	for {
+		err := c.Connect(ctx, addr)
+		if err == nil {
+			b.reset()
+			if restore {
+				c.restore(ctx)
+			}
+			restore = true
+
+			select {
+			case <-c.Done():
+				log.Warnf("Connection to %s lost: %+v", addr, c.Err())
+			case <-ctx.Done():
+				timeout := cfg.CloseTimeout
+				if timeout <= 0 {
+					timeout = defaultCloseTimeout
+				}
+				closeCtx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				return c.Close(closeCtx)
+			}
+		} else if ctx.Err() != nil {
+			return nil
+		} else if isFatalConnectError(err) {
+			return err
+		} else {
+			log.Warnf("Failed to connect to %s: %+v", addr, err)
+			if b.exhausted() {
+				return ErrTooManyAttempts
+			}
+		}
+
+		select {
+		case <-time.After(b.next()):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// restore rejoins the channels which were joined before the connection got lost.
+func (c *Conn) restore(ctx context.Context) {
+	for channel, key := range c.Channels() {
+		joinCtx, cancel := context.WithTimeout(ctx, defaultJoinTimeout)
+		var err error
+		if key != "" {
+			err = c.Join(joinCtx, channel, key)
+		} else {
+			err = c.Join(joinCtx, channel)
+		}
+		cancel()
+		if err != nil {
+			log.Errorf("Failed to rejoin channel %s: %+v", channel, err)
+		}
+	}
+}
+
+// isFatalConnectError reports whether retrying the connection is pointless.
+func isFatalConnectError(err error) bool {
+	var saslErr *SASLError
+	return errors.As(err, &saslErr) || errors.Is(err, ErrSASLUnavailable)
+}
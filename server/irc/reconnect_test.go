@@ -0,0 +1,167 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconnect(t *testing.T) {
+	require := require.New(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(err)
+	defer listener.Close()
+
+	c := NewConn(Config{
+		Nick: "chatto",
+		Reconnect: ReconnectConfig{
+			MinDelay: 10 * time.Millisecond,
+			MaxDelay: 50 * time.Millisecond,
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	disconnected := make(chan error, 1)
+	c.Each(ctx, DISCONNECTED, func(e Event) {
+		disconnected <- e.Error
+	})
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c.Run(ctx, listener.Addr().String())
+	}()
+
+	accept := func() *testServer {
+		conn, err := listener.Accept()
+		require.Nil(err)
+		srv := newTestServerConn(require, conn)
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+		return srv
+	}
+
+	srv := accept()
+	joined := make(chan error, 1)
+	go func() {
+		joined <- c.Join(ctx, "#secret", "key")
+	}()
+	srv.expect("JOIN #secret key")
+	srv.send(":chatto!chatto@host JOIN #secret")
+	require.Nil(<-joined)
+
+	// Dropping the connection emits DISCONNECTED with the cause and reconnects
+	srv.close()
+	select {
+	case err := <-disconnected:
+		require.Equal(ErrConnectionLost, err)
+	case <-time.After(time.Second):
+		require.FailNow("Expected DISCONNECTED event before timeout")
+	}
+
+	srv = accept()
+	srv.expect("JOIN #secret key")
+	srv.send(":chatto!chatto@host JOIN #secret")
+	require.Eventually(func() bool {
+		return c.Connected()
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(map[string]string{"#secret": "key"}, c.Channels())
+
+	// Cancelling the context closes the connection gracefully
+	cancel()
+	srv.expect(QUIT)
+	srv.close()
+	require.Nil(<-ch)
+	require.False(c.Connected())
+}
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+	b := newBackoff(ReconnectConfig{
+		MinDelay:    100 * time.Millisecond,
+		MaxDelay:    time.Second,
+		Jitter:      0.1,
+		MaxAttempts: 6,
+	})
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for _, delay := range expected {
+		assert.False(b.exhausted())
+		next := b.next()
+		assert.GreaterOrEqual(int64(next), int64(float64(delay)*0.9))
+		assert.LessOrEqual(int64(next), int64(float64(delay)*1.1))
+	}
+	assert.True(b.exhausted())
+	b.reset()
+	assert.False(b.exhausted())
+}
+
+// failingWriter fails the writes once broken.
+type failingWriter struct {
+	io.Writer
+	broken int32
+}
+
+var errBrokenWriter = errors.New("broken writer")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&w.broken) != 0 {
+		return 0, errBrokenWriter
+	}
+	return w.Writer.Write(p)
+}
+
+func TestSendError(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto"})
+	disconnected := make(chan error, 1)
+	c.Once(context.Background(), DISCONNECTED, func(e Event) {
+		disconnected <- e.Error
+	})
+	w := &failingWriter{Writer: conn}
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{conn, w}
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c.Connect(context.Background(), rw)
+	}()
+	srv.expect("NICK chatto")
+	srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+	srv.send(
+		":irc.test 001 chatto :Welcome",
+		":irc.test 376 chatto :End of /MOTD command.",
+	)
+	require.Nil(<-ch)
+
+	// Failing to write a line disconnects with the cause
+	atomic.StoreInt32(&w.broken, 1)
+	require.Nil(c.Privmsg(context.Background(), "#foo", "lost"))
+	select {
+	case err := <-disconnected:
+		require.Equal(errBrokenWriter, err)
+	case <-time.After(time.Second):
+		require.FailNow("Expected DISCONNECTED event before timeout")
+	}
+	require.False(c.Connected())
+}
@@ -0,0 +1,327 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// motdTimeout bounds the wait for the end of the MOTD once welcomed, for the
+// servers which send neither the MOTD nor ERR_NOMOTD.
+const motdTimeout = 5 * time.Second
+
+// welcomeBurst are the replies sent between the welcome and the end of the
+// MOTD, any other line means the server is done with the registration.
+var welcomeBurst = []string{
+	RPL_YOURHOST, RPL_CREATED, RPL_MYINFO, RPL_ISUPPORT,
+	RPL_LUSERCLIENT, RPL_LUSEROP, RPL_LUSERUNKNOWN, RPL_LUSERCHANNELS, RPL_LUSERME,
+	RPL_LOCALUSERS, RPL_GLOBALUSERS, RPL_MOTDSTART, RPL_MOTD,
+}
+
+// WebIRCConfig holds the credentials of a WEBIRC gateway along with the real
+// host of the user it connects on behalf of.
+type WebIRCConfig struct {
+	Password string
+	Gateway  string
+	Hostname string
+	IP       string
+	// Options are sent as flags, e.g. "secure" when the user connected with TLS
+	Options []string
+}
+
+// registration drives the connection registration: capability negotiation,
+// NICK/USER and waiting for the welcome reply.
+type registration struct {
+	client *Client
+	msgs   <-chan Message
+
+	nick string
+	// primary is the nick the strategy derives the others from
+	primary string
+	tried   []string
+	attempt int
+
+	ended     bool
+	caps      []string
+	capLs     []string
+	capsReq   int
+	capEnded  bool
+	welcomed  bool
+	negotiate bool
+
+	sasl      saslMechanism
+	saslChunk saslDecoder
+	saslMechs []string
+}
+
+func (c *Client) register(ctx context.Context) error {
+	regCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	msgs := make(chan Message, 16)
+	obs := c.Each(regCtx, RAW, func(e Event) {
+		select {
+		case msgs <- e.Message:
+		case <-regCtx.Done():
+		}
+	})
+	defer obs.Remove()
+
+	r := &registration{
+		client:  c,
+		msgs:    msgs,
+		nick:    c.preferredNick(),
+		primary: c.preferredNick(),
+		caps:    c.cfg.Caps,
+	}
+	if c.cfg.SASL != nil {
+		r.caps = append(append([]string{}, r.caps...), "sasl")
+	}
+	r.negotiate = len(r.caps) > 0
+	return r.run(regCtx)
+}
+
+func (r *registration) run(ctx context.Context) error {
+	c := r.client
+	if w := c.cfg.WebIRC; w != nil {
+		if err := c.WebIRC(ctx, w.Password, w.Gateway, w.Hostname, w.IP, w.Options...); err != nil {
+			return err
+		}
+	}
+	if r.negotiate {
+		if err := c.Command(ctx, CAP, CAP_LS, "302"); err != nil {
+			return err
+		}
+	}
+	if c.cfg.Password != "" {
+		if err := c.Pass(ctx, c.cfg.Password); err != nil {
+			return err
+		}
+	}
+	if err := c.Commands.Nick(ctx, r.nick); err != nil {
+		return err
+	}
+	if err := c.User(ctx, c.cfg.Ident, c.cfg.Name); err != nil {
+		return err
+	}
+
+	done := c.Done()
+	var motd <-chan time.Time
+	for !r.ended {
+		select {
+		case msg := <-r.msgs:
+			if err := r.handle(ctx, msg); err != nil {
+				return err
+			}
+			if r.welcomed && motd == nil {
+				timer := time.NewTimer(motdTimeout)
+				defer timer.Stop()
+				motd = timer.C
+			}
+		case <-motd:
+			r.ended = true
+		case <-done:
+			return c.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	c.nick = r.nick
+	c.mu.Unlock()
+	return nil
+}
+
+func (r *registration) handle(ctx context.Context, msg Message) error {
+	// The end of the MOTD or any line following the welcome burst ends the
+	// registration, since not every server or bouncer sends a MOTD
+	if r.welcomed && !containsString(welcomeBurst, msg.Cmd) {
+		r.ended = true
+		return nil
+	}
+	switch msg.Cmd {
+	case RPL_WELCOME:
+		r.welcomed = true
+		if len(msg.Args) > 0 && msg.Args[0] != "" {
+			r.nick = msg.Args[0]
+		}
+		// The welcome message usually ends with our full nick!user@host
+		if len(msg.Args) > 0 {
+			fields := strings.Fields(msg.Args[len(msg.Args)-1])
+			if len(fields) > 0 {
+				r.client.trackSource(fields[len(fields)-1])
+			}
+		}
+	case RPL_ISUPPORT:
+		// Parsed here as well so the tokens are known once Connect returns
+		if len(msg.Args) > 2 {
+			r.client.updateISupport(msg.Args[1 : len(msg.Args)-1])
+		}
+	case ERR_NONICKNAMEGIVEN, ERR_ERRONEUSNICKNAME, ERR_NICKNAMEINUSE, ERR_NICKCOLLISION, ERR_UNAVAILRESOURCE:
+		return r.nextNick(ctx, msg)
+	case ERR_PASSWDMISMATCH:
+		return replyError(PASS, msg)
+	case ERR_UNKNOWNCOMMAND:
+		// The server doesn't support capability negotiation at all
+		if len(msg.Args) > 1 && msg.Args[1] == CAP {
+			r.capEnded = true
+			if r.client.cfg.SASL != nil {
+				return ErrSASLUnavailable
+			}
+		}
+	case CAP:
+		return r.handleCap(ctx, msg)
+	case AUTHENTICATE:
+		return r.handleAuthenticate(ctx, msg)
+	case RPL_SASLSUCCESS:
+		return r.endCap(ctx)
+	case RPL_SASLMECHS:
+		if len(msg.Args) > 1 {
+			r.saslMechs = strings.Split(msg.Args[1], ",")
+		}
+	case ERR_NICKLOCKED, ERR_SASLFAIL, ERR_SASLTOOLONG, ERR_SASLABORTED, ERR_SASLALREADY:
+		return r.saslError(msg)
+	}
+	return nil
+}
+
+// nextNick tries the next nick of the strategy once the current one is rejected.
+func (r *registration) nextNick(ctx context.Context, msg Message) error {
+	c := r.client
+	// "<client> <nick> :<reason>", ignoring the replies about a nick already replaced
+	if r.welcomed || (len(msg.Args) > 2 && !c.isupport.EqualFold(msg.Args[1], r.nick)) {
+		return nil
+	}
+	r.tried = append(r.tried, r.nick)
+	nickLen := 0
+	if c.isupport.Has("NICKLEN") {
+		nickLen = c.isupport.NickLen()
+	}
+	for r.attempt < c.cfg.Nicks.MaxAttempts {
+		r.attempt++
+		nick, ok := c.cfg.Nicks.Strategy.Next(r.primary, r.attempt, nickLen)
+		if !ok {
+			break
+		}
+		// Truncated nicks may end up the same
+		if nick != "" && !c.containsNick(r.tried, nick) {
+			r.nick = nick
+			return c.Commands.Nick(ctx, r.nick)
+		}
+	}
+	return fmt.Errorf("%w, tried %s", ErrNickUnavailable, strings.Join(r.tried, ", "))
+}
+
+func (r *registration) handleCap(ctx context.Context, msg Message) error {
+	if r.capEnded || len(msg.Args) < 3 {
+		return nil
+	}
+	c := r.client
+	subcmd, caps := msg.Args[1], msg.Args[len(msg.Args)-1]
+	switch subcmd {
+	case CAP_LS:
+		r.capLs = append(r.capLs, caps)
+		// Multiline replies have an asterisk before the final parameter
+		if len(msg.Args) > 3 && msg.Args[2] == "*" {
+			return nil
+		}
+		c.caps.advertise(strings.Join(r.capLs, " "))
+		wanted := c.caps.wanted(r.caps)
+		if len(wanted) <= 0 {
+			return r.finishCap(ctx)
+		}
+		r.capsReq++
+		return c.Command(ctx, CAP, CAP_REQ, ":"+strings.Join(wanted, " "))
+	case CAP_ACK, CAP_NAK:
+		if subcmd == CAP_ACK {
+			c.caps.acknowledge(caps)
+		}
+		r.capsReq--
+		if r.capsReq <= 0 {
+			return r.finishCap(ctx)
+		}
+	}
+	return nil
+}
+
+// finishCap authenticates when SASL is configured before ending the negotiation.
+func (r *registration) finishCap(ctx context.Context) error {
+	c := r.client
+	cfg := c.cfg.SASL
+	if cfg == nil {
+		return r.endCap(ctx)
+	}
+	if !c.caps.has("sasl") {
+		return ErrSASLUnavailable
+	}
+	if mechs, _ := c.caps.value("sasl"); mechs != "" && !containsString(strings.Split(mechs, ","), cfg.Mechanism) {
+		return &SASLError{
+			Code:       RPL_SASLMECHS,
+			Message:    "mechanism " + cfg.Mechanism + " is not available",
+			Mechanisms: strings.Split(mechs, ","),
+		}
+	}
+	mech, err := newSASLMechanism(cfg)
+	if err != nil {
+		return err
+	}
+	r.sasl = mech
+	return c.Command(ctx, AUTHENTICATE, cfg.Mechanism)
+}
+
+func (r *registration) handleAuthenticate(ctx context.Context, msg Message) error {
+	if r.sasl == nil || len(msg.Args) < 1 {
+		return nil
+	}
+	challenge, done, err := r.saslChunk.feed(msg.Args[0])
+	if err != nil {
+		return r.abortSASL(ctx, err)
+	}
+	if !done {
+		return nil
+	}
+	response, err := r.sasl.Next(challenge)
+	if err != nil {
+		return r.abortSASL(ctx, err)
+	}
+	for _, chunk := range encodeSASLPayload(response) {
+		if err := r.client.Command(ctx, AUTHENTICATE, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *registration) abortSASL(ctx context.Context, err error) error {
+	if cerr := r.client.Command(ctx, AUTHENTICATE, "*"); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+func (r *registration) saslError(msg Message) error {
+	err := &SASLError{
+		Code:       msg.Cmd,
+		Mechanisms: r.saslMechs,
+	}
+	if len(msg.Args) > 0 {
+		err.Message = msg.Args[len(msg.Args)-1]
+	}
+	return err
+}
+
+func (r *registration) endCap(ctx context.Context) error {
+	r.capEnded = true
+	return r.client.Command(ctx, CAP, CAP_END)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
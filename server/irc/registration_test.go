@@ -0,0 +1,84 @@
+package irc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistrationOrder(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{
+		Nick:     "chatto",
+		Ident:    "bot",
+		Name:     "Chatto Bot",
+		UserMode: "8",
+		Password: "server secret",
+		WebIRC: &WebIRCConfig{
+			Password: "gateway-secret",
+			Gateway:  "chatto-gateway",
+			Hostname: "user.host",
+			IP:       "192.0.2.1",
+			Options:  []string{"secure"},
+		},
+		Caps: []string{"multi-prefix"},
+	})
+	err := connectClient(c, conn, func() {
+		srv.expect("WEBIRC gateway-secret chatto-gateway user.host 192.0.2.1 secure")
+		srv.expect("CAP LS 302")
+		srv.expect("PASS :server secret")
+		srv.expect("NICK chatto")
+		srv.expect("USER bot 8 * :Chatto Bot")
+		srv.send(":irc.test CAP * LS :multi-prefix")
+		srv.expect("CAP REQ :multi-prefix")
+		srv.send(":irc.test CAP * ACK :multi-prefix")
+		srv.expect("CAP END")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+	require.Nil(closeClient(c, srv))
+}
+
+func TestRegistrationPassword(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto", Password: "wrong"})
+	err := connectClient(c, conn, func() {
+		srv.expect("PASS wrong")
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(":irc.test 464 chatto :Password incorrect")
+	})
+	require.True(errors.Is(err, ErrPasswdMismatch))
+	require.False(c.Connected())
+}
+
+func TestRegistrationWithoutMOTD(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	// The first line following the welcome burst ends the registration
+	c := NewClient(Config{Nick: "chatto"})
+	err := connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 005 chatto NETWORK=Chatto :are supported by this server",
+			":chatto MODE chatto :+i",
+		)
+	})
+	require.Nil(err)
+	require.Equal("Chatto", c.ISupport().Network())
+	require.Nil(closeClient(c, srv))
+}
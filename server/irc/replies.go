@@ -0,0 +1,118 @@
+package irc
+
+// Replies from RFC 1459, RFC 2812 and the modern IRC client protocol
+const (
+	RPL_WELCOME         = "001"
+	RPL_YOURHOST        = "002"
+	RPL_CREATED         = "003"
+	RPL_MYINFO          = "004"
+	RPL_ISUPPORT        = "005"
+	RPL_BOUNCE          = "010"
+	RPL_TRACELINK       = "200"
+	RPL_TRACECONNECTING = "201"
+	RPL_TRACEHANDSHAKE  = "202"
+	RPL_TRACEUNKNOWN    = "203"
+	RPL_TRACEOPERATOR   = "204"
+	RPL_TRACEUSER       = "205"
+	RPL_TRACESERVER     = "206"
+	RPL_TRACESERVICE    = "207"
+	RPL_TRACENEWTYPE    = "208"
+	RPL_TRACECLASS      = "209"
+	RPL_STATSLINKINFO   = "211"
+	RPL_STATSCOMMANDS   = "212"
+	RPL_ENDOFSTATS      = "219"
+	RPL_UMODEIS         = "221"
+	RPL_SERVLIST        = "234"
+	RPL_SERVLISTEND     = "235"
+	RPL_STATSUPTIME     = "242"
+	RPL_STATSOLINE      = "243"
+	RPL_LUSERCLIENT     = "251"
+	RPL_LUSEROP         = "252"
+	RPL_LUSERUNKNOWN    = "253"
+	RPL_LUSERCHANNELS   = "254"
+	RPL_LUSERME         = "255"
+	RPL_ADMINME         = "256"
+	RPL_ADMINLOC1       = "257"
+	RPL_ADMINLOC2       = "258"
+	RPL_ADMINEMAIL      = "259"
+	RPL_TRACELOG        = "261"
+	RPL_TRACEEND        = "262"
+	RPL_TRYAGAIN        = "263"
+	RPL_LOCALUSERS      = "265"
+	RPL_GLOBALUSERS     = "266"
+	RPL_WHOISCERTFP     = "276"
+	RPL_NONE            = "300"
+	RPL_AWAY            = "301"
+	RPL_USERHOST        = "302"
+	RPL_ISON            = "303"
+	RPL_UNAWAY          = "305"
+	RPL_NOWAWAY         = "306"
+	RPL_WHOISREGNICK    = "307"
+	RPL_WHOISUSER       = "311"
+	RPL_WHOISSERVER     = "312"
+	RPL_WHOISOPERATOR   = "313"
+	RPL_WHOWASUSER      = "314"
+	RPL_ENDOFWHO        = "315"
+	RPL_WHOISIDLE       = "317"
+	RPL_ENDOFWHOIS      = "318"
+	RPL_WHOISCHANNELS   = "319"
+	RPL_WHOISSPECIAL    = "320"
+	RPL_LISTSTART       = "321"
+	RPL_LIST            = "322"
+	RPL_LISTEND         = "323"
+	RPL_CHANNELMODEIS   = "324"
+	RPL_UNIQOPIS        = "325"
+	RPL_CREATIONTIME    = "329"
+	RPL_WHOISACCOUNT    = "330"
+	RPL_NOTOPIC         = "331"
+	RPL_TOPIC           = "332"
+	RPL_TOPICWHOTIME    = "333"
+	RPL_INVITELIST      = "336"
+	RPL_ENDOFINVITELIST = "337"
+	RPL_WHOISACTUALLY   = "338"
+	RPL_INVITING        = "341"
+	RPL_SUMMONING       = "342"
+	RPL_INVEXLIST       = "346"
+	RPL_ENDOFINVEXLIST  = "347"
+	RPL_EXCEPTLIST      = "348"
+	RPL_ENDOFEXCEPTLIST = "349"
+	RPL_VERSION         = "351"
+	RPL_WHOREPLY        = "352"
+	RPL_NAMREPLY        = "353"
+	RPL_WHOSPCRPL       = "354"
+	RPL_LINKS           = "364"
+	RPL_ENDOFLINKS      = "365"
+	RPL_ENDOFNAMES      = "366"
+	RPL_BANLIST         = "367"
+	RPL_ENDOFBANLIST    = "368"
+	RPL_ENDOFWHOWAS     = "369"
+	RPL_INFO            = "371"
+	RPL_MOTD            = "372"
+	RPL_ENDOFINFO       = "374"
+	RPL_MOTDSTART       = "375"
+	RPL_ENDOFMOTD       = "376"
+	RPL_WHOISHOST       = "378"
+	RPL_WHOISMODES      = "379"
+	RPL_YOUREOPER       = "381"
+	RPL_REHASHING       = "382"
+	RPL_YOURESERVICE    = "383"
+	RPL_TIME            = "391"
+	RPL_USERSSTART      = "392"
+	RPL_USERS           = "393"
+	RPL_ENDOFUSERS      = "394"
+	RPL_NOUSERS         = "395"
+	RPL_HOSTHIDDEN      = "396"
+	RPL_STARTTLS        = "670"
+	RPL_WHOISSECURE     = "671"
+	RPL_HELPSTART       = "704"
+	RPL_HELPTXT         = "705"
+	RPL_ENDOFHELP       = "706"
+	RPL_MONONLINE       = "730"
+	RPL_MONOFFLINE      = "731"
+	RPL_MONLIST         = "732"
+	RPL_ENDOFMONLIST    = "733"
+	RPL_LOGGEDIN        = "900"
+	RPL_LOGGEDOUT       = "901"
+	RPL_SASLSUCCESS     = "903"
+	RPL_SASLMECHS       = "908"
+)
@@ -0,0 +1,81 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var ErrNoReplyTarget = errors.New("no target to reply to")
+
+// ReplyOption changes how Reply and ReplyNotice answer the message.
+type ReplyOption int
+
+const (
+	// REPLY_MENTION prefixes the reply with the nick of the sender when it goes
+	// to a channel, like "alice: text"
+	REPLY_MENTION ReplyOption = iota + 1
+)
+
+// Reply answers the message in the channel it was sent to, or privately to
+// its sender otherwise. The reply refers to the message when the server
+// supports message tags.
+func (e Event) Reply(ctx context.Context, text string, opts ...ReplyOption) error {
+	return e.reply(ctx, PRIVMSG, e.replyTarget(), e.replyText(text, opts))
+}
+
+// ReplyNotice answers the message like Reply, with a NOTICE.
+func (e Event) ReplyNotice(ctx context.Context, text string, opts ...ReplyOption) error {
+	return e.reply(ctx, NOTICE, e.replyTarget(), e.replyText(text, opts))
+}
+
+// ReplyPrivately answers the sender of the message privately, even when it
+// was sent to a channel.
+func (e Event) ReplyPrivately(ctx context.Context, text string) error {
+	return e.reply(ctx, PRIVMSG, e.sender(), text)
+}
+
+func (e Event) replyText(text string, opts []ReplyOption) string {
+	for _, opt := range opts {
+		if opt != REPLY_MENTION {
+			continue
+		}
+		if nick := e.sender(); nick != "" && e.replyTarget() != nick {
+			return nick + ": " + text
+		}
+	}
+	return text
+}
+
+func (e Event) reply(ctx context.Context, cmd string, target string, text string) error {
+	if e.Client == nil || target == "" {
+		return ErrNoReplyTarget
+	}
+	var tags Tags
+	if msgid := e.Message.Tags["msgid"]; msgid != "" && e.Client.HasCap("message-tags") {
+		tags = Tags{"+draft/reply": msgid}
+	}
+	return e.Client.message(ctx, tags, cmd, target, "", text)
+}
+
+// replyTarget returns the channel the message was sent to, or its sender.
+// The messages sent to the members of a channel with a given status, like
+// "@#chatto", are answered to the same members.
+func (e Event) replyTarget() string {
+	if e.Client == nil || len(e.Message.Args) <= 0 {
+		return e.sender()
+	}
+	target := e.Message.Args[0]
+	channel := strings.TrimLeft(target, e.Client.isupport.StatusMsg())
+	if e.Client.isupport.IsChannel(channel) {
+		return target
+	}
+	return e.sender()
+}
+
+func (e Event) sender() string {
+	if e.Message.Nick != "" {
+		return e.Message.Nick
+	}
+	return e.Message.Src
+}
@@ -0,0 +1,123 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReply(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	ctx := context.Background()
+	events := make(chan Event, 1)
+	c.Each(ctx, PRIVMSG, func(e Event) {
+		events <- e
+	})
+	reply := func(send func() error, expected string) {
+		errs := make(chan error, 1)
+		go func() {
+			errs <- send()
+		}()
+		srv.expect(expected)
+		require.Nil(<-errs)
+	}
+
+	// Channel messages are answered in the channel
+	srv.send(":alice!~alice@host PRIVMSG #chatto :.echo Hi")
+	e := <-events
+	reply(func() error { return e.Reply(ctx, "Hi") }, "PRIVMSG #chatto :Hi")
+	reply(func() error { return e.ReplyNotice(ctx, "Hi") }, "NOTICE #chatto :Hi")
+	reply(func() error { return e.ReplyPrivately(ctx, "Hi") }, "PRIVMSG alice :Hi")
+	reply(func() error { return e.Reply(ctx, "Hi", REPLY_MENTION) }, "PRIVMSG #chatto :alice: Hi")
+	reply(func() error { return e.ReplyNotice(ctx, "Hi", REPLY_MENTION) }, "NOTICE #chatto :alice: Hi")
+
+	// Messages to the members with a status are answered to the same members
+	srv.send(":irc.test 005 chatto STATUSMSG=@+ :are supported by this server")
+	require.Eventually(func() bool {
+		return c.ISupport().StatusMsg() == "@+"
+	}, time.Second, 10*time.Millisecond)
+	srv.send(":alice!~alice@host PRIVMSG @#chatto :.echo Hi")
+	e = <-events
+	reply(func() error { return e.Reply(ctx, "Hi") }, "PRIVMSG @#chatto :Hi")
+	reply(func() error { return e.Reply(ctx, "Hi", REPLY_MENTION) }, "PRIVMSG @#chatto :alice: Hi")
+
+	// Private messages are answered to their sender
+	srv.send(":alice!~alice@host PRIVMSG chatto :.echo Hi")
+	e = <-events
+	reply(func() error { return e.Reply(ctx, "Hi") }, "PRIVMSG alice :Hi")
+	reply(func() error { return e.ReplyNotice(ctx, "Hi") }, "NOTICE alice :Hi")
+	reply(func() error { return e.Reply(ctx, "Hi", REPLY_MENTION) }, "PRIVMSG alice :Hi")
+
+	// Typed events reply the same way
+	messages := make(chan PrivmsgEvent, 1)
+	c.OnPrivmsg(ctx, func(e PrivmsgEvent) {
+		messages <- e
+	})
+	srv.send(":alice!~alice@host PRIVMSG chatto :Hello")
+	<-events
+	msg := <-messages
+	reply(func() error { return msg.Reply(ctx, "Hello") }, "PRIVMSG alice :Hello")
+
+	// Without the message-tags capability the reply tag isn't sent
+	srv.send("@msgid=abc :alice!~alice@host PRIVMSG #chatto :Hi")
+	e = <-events
+	<-messages
+	reply(func() error { return e.Reply(ctx, "Hi") }, "PRIVMSG #chatto :Hi")
+
+	require.True(errors.Is(Event{}.Reply(ctx, "Hi"), ErrNoReplyTarget))
+	require.Nil(closeClient(c, srv))
+}
+
+func TestReplyTags(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto", Flood: FloodConfig{Burst: -1}, Caps: []string{"message-tags"}})
+	require.Nil(connectClient(c, conn, func() {
+		srv.expect("CAP LS 302")
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(":irc.test CAP * LS :message-tags")
+		srv.expect("CAP REQ :message-tags")
+		srv.send(":irc.test CAP * ACK :message-tags")
+		srv.expect("CAP END")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	}))
+
+	ctx := context.Background()
+	events := make(chan Event, 1)
+	c.Each(ctx, PRIVMSG, func(e Event) {
+		events <- e
+	})
+	srv.send("@msgid=abc;time=2020-10-10T10:10:10.000Z :alice!~alice@host PRIVMSG #chatto :Hi")
+	e := <-events
+	errs := make(chan error, 1)
+	go func() {
+		errs <- e.Reply(ctx, "Hi\nthere")
+	}()
+	// Each line of the reply refers to the message
+	srv.expect("@+draft/reply=abc PRIVMSG #chatto :Hi")
+	srv.expect("@+draft/reply=abc PRIVMSG #chatto :there")
+	require.Nil(<-errs)
+
+	// Messages without msgid are answered without tags
+	srv.send(":alice!~alice@host PRIVMSG chatto :Hi")
+	e = <-events
+	go func() {
+		errs <- e.ReplyPrivately(ctx, "Hi")
+	}()
+	srv.expect("PRIVMSG alice :Hi")
+	require.Nil(<-errs)
+
+	require.Nil(closeClient(c, srv))
+}
@@ -0,0 +1,297 @@
+package irc
+
+import (
+	"chatto/util/stream"
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	ACK   = "ACK"
+	BATCH = "BATCH"
+	FAIL  = "FAIL"
+	ERROR = "ERROR"
+)
+
+// replySpec declares how the server answers a command.
+type replySpec struct {
+	// success lists the commands or numerics completing the command
+	success []string
+	// failure lists the error numerics failing the command
+	failure []string
+	// targets are the indexes of the arguments identifying the request, which
+	// the replies must mention
+	targets []int
+	// self requires the successful reply to come from ourselves
+	self bool
+}
+
+// Numerics failing any command, they mention the command instead of a target
+var commandFailures = []string{ERR_UNKNOWNCOMMAND, ERR_NEEDMOREPARAMS}
+
+var replySpecs = map[string]replySpec{
+	JOIN: {
+		success: []string{JOIN},
+		failure: []string{
+			ERR_NOSUCHCHANNEL, ERR_TOOMANYCHANNELS, ERR_CHANNELISFULL, ERR_INVITEONLYCHAN,
+			ERR_BANNEDFROMCHAN, ERR_BADCHANNELKEY, ERR_BADCHANMASK, ERR_NEEDREGGEDNICK,
+			ERR_UNAVAILRESOURCE,
+		},
+		targets: []int{0},
+		self:    true,
+	},
+	PART: {
+		success: []string{PART},
+		failure: []string{ERR_NOSUCHCHANNEL, ERR_NOTONCHANNEL},
+		targets: []int{0},
+		self:    true,
+	},
+	NICK: {
+		// Our nick may already be updated by the time the reply is checked
+		success: []string{NICK},
+		failure: []string{ERR_ERRONEUSNICKNAME, ERR_NICKNAMEINUSE, ERR_NICKCOLLISION, ERR_UNAVAILRESOURCE},
+		targets: []int{0},
+	},
+	TOPIC: {
+		success: []string{TOPIC, RPL_TOPIC, RPL_NOTOPIC},
+		failure: []string{ERR_NOSUCHCHANNEL, ERR_NOTONCHANNEL, ERR_CHANOPRIVSNEEDED},
+		targets: []int{0},
+	},
+	KICK: {
+		success: []string{KICK},
+		failure: []string{ERR_NOSUCHCHANNEL, ERR_USERNOTINCHANNEL, ERR_NOTONCHANNEL, ERR_CHANOPRIVSNEEDED},
+		targets: []int{0},
+		self:    true,
+	},
+	INVITE: {
+		success: []string{RPL_INVITING},
+		failure: []string{ERR_NOSUCHNICK, ERR_NOSUCHCHANNEL, ERR_NOTONCHANNEL, ERR_USERONCHANNEL, ERR_CHANOPRIVSNEEDED},
+		targets: []int{0, 1},
+	},
+	AWAY: {
+		success: []string{RPL_UNAWAY, RPL_NOWAWAY},
+	},
+	OPER: {
+		success: []string{RPL_YOUREOPER},
+		failure: []string{ERR_PASSWDMISMATCH, ERR_NOOPERHOST},
+	},
+	SETNAME: {
+		success: []string{SETNAME},
+		self:    true,
+	},
+	QUIT: {
+		// The server echoes our QUIT or sends ERROR before closing the link,
+		// which may be closed right away as well
+		success: []string{QUIT, ERROR},
+		self:    true,
+	},
+}
+
+// request correlates the replies of a command sent with CommandWait.
+type request struct {
+	commands *Commands
+	cmd      string
+	spec     replySpec
+	targets  []string
+
+	// label is set when the command is sent with labeled-response
+	label string
+	batch string
+	// reply is the successful reply seen within a labeled batch
+	reply *Message
+}
+
+func newRequest(c *Commands, cmd string, args []string) *request {
+	r := &request{commands: c, cmd: cmd}
+	spec, ok := replySpecs[cmd]
+	if !ok {
+		// Wait for the command to be echoed back by default
+		spec = replySpec{success: []string{cmd}}
+	}
+	r.spec = spec
+	for _, idx := range spec.targets {
+		if idx < len(args) {
+			r.targets = append(r.targets, strings.Split(args[idx], ",")...)
+		}
+	}
+	return r
+}
+
+// handle checks whether the message answers the request, returning the
+// successful reply or the error once it is done.
+func (r *request) handle(msg Message) (Message, bool, error) {
+	if r.label != "" {
+		return r.handleLabeled(msg)
+	}
+	if msg.Cmd == FAIL && len(msg.Args) > 1 && strings.EqualFold(msg.Args[0], r.cmd) {
+		return Message{}, true, replyError(r.cmd, msg)
+	}
+	if containsString(commandFailures, msg.Cmd) && len(msg.Args) > 1 && strings.EqualFold(msg.Args[1], r.cmd) {
+		return Message{}, true, replyError(r.cmd, msg)
+	}
+	if containsString(r.spec.failure, msg.Cmd) && r.mentions(msg) {
+		return Message{}, true, replyError(r.cmd, msg)
+	}
+	if containsString(r.spec.success, msg.Cmd) && r.mentions(msg) {
+		// ERROR comes from the server about our own link
+		if r.spec.self && !isNumeric(msg.Cmd) && msg.Cmd != ERROR && r.commands.self != nil && !r.commands.self(msg.Nick) {
+			return Message{}, false, nil
+		}
+		return msg, true, nil
+	}
+	return Message{}, false, nil
+}
+
+func (r *request) handleLabeled(msg Message) (Message, bool, error) {
+	if label, _ := msg.Tags.Get("label"); label == r.label {
+		switch {
+		case msg.Cmd == ACK:
+			return msg, true, nil
+		case msg.Cmd == BATCH && len(msg.Args) > 0 && strings.HasPrefix(msg.Args[0], "+"):
+			r.batch = msg.Args[0][1:]
+			return Message{}, false, nil
+		}
+		return r.labeledReply(msg, true)
+	}
+	if r.batch == "" {
+		return Message{}, false, nil
+	}
+	if msg.Cmd == BATCH && len(msg.Args) > 0 && msg.Args[0] == "-"+r.batch {
+		if r.reply != nil {
+			return *r.reply, true, nil
+		}
+		return msg, true, nil
+	}
+	if batch, _ := msg.Tags.Get("batch"); batch == r.batch {
+		return r.labeledReply(msg, false)
+	}
+	return Message{}, false, nil
+}
+
+// labeledReply handles a reply to our label, the last one unless it is part of a batch.
+func (r *request) labeledReply(msg Message, last bool) (Message, bool, error) {
+	isError := msg.Cmd == FAIL || msg.Cmd == ERROR ||
+		containsString(commandFailures, msg.Cmd) || containsString(r.spec.failure, msg.Cmd)
+	if isError {
+		return Message{}, true, replyError(r.cmd, msg)
+	}
+	if r.reply == nil && containsString(r.spec.success, msg.Cmd) {
+		reply := msg
+		r.reply = &reply
+	}
+	if last {
+		return msg, true, nil
+	}
+	return Message{}, false, nil
+}
+
+// mentions reports whether the reply is about one of the request targets.
+func (r *request) mentions(msg Message) bool {
+	if len(r.targets) <= 0 {
+		return true
+	}
+	params := msg.Args
+	if isNumeric(msg.Cmd) {
+		// Skip our nick and the trailing description
+		if len(params) < 2 {
+			return false
+		}
+		params = params[1:]
+		if len(params) > 1 {
+			params = params[:len(params)-1]
+		}
+	} else if len(params) > 0 {
+		params = params[:1]
+	}
+	for _, param := range params {
+		for _, target := range r.targets {
+			if r.commands.equalFold(param, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func replyError(cmd string, msg Message) error {
+	err := &ReplyError{Code: msg.Cmd, Command: cmd, Reply: msg}
+	if msg.Cmd == FAIL {
+		err.Code = msg.Args[1]
+	}
+	if len(msg.Args) > 0 {
+		err.Message = msg.Args[len(msg.Args)-1]
+	}
+	return err
+}
+
+// CommandWait sends the command and waits for the server to answer it. The
+// command completes on its success reply and fails with a *ReplyError on its
+// error numerics, using labeled-response to correlate the replies when the
+// server supports it.
+func (c *Commands) CommandWait(ctx context.Context, cmd string, args ...string) (Message, error) {
+	msgs, stop := c.observeReplies(ctx)
+	defer stop()
+
+	r := newRequest(c, cmd, args)
+	var err error
+	// The server closes the connection rather than answering a QUIT
+	if c.labeled != nil && c.labeled() && cmd != QUIT {
+		r.label = strconv.FormatUint(atomic.AddUint64(&c.labels, 1), 36)
+		err = c.TagCommand(ctx, Tags{"label": r.label}, cmd, args...)
+	} else {
+		err = c.Command(ctx, cmd, args...)
+	}
+	if err != nil {
+		return Message{}, err
+	}
+
+	done := c.connDone()
+	for {
+		select {
+		case msg := <-msgs:
+			reply, ok, err := r.handle(msg)
+			if ok {
+				return reply, err
+			}
+		case <-done:
+			if cmd == QUIT {
+				// Losing the connection is the expected outcome
+				return Message{}, nil
+			}
+			return Message{}, ErrNotConnected
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+// observeReplies forwards the received messages in order until stopped.
+func (c *Commands) observeReplies(ctx context.Context) (<-chan Message, func()) {
+	obsCtx, cancel := context.WithCancel(ctx)
+	msgs := make(chan Message, 16)
+	obs := c.stream.Each(obsCtx, RAW, func(item stream.Item) {
+		msg, ok := item.V.(Message)
+		if !ok {
+			return
+		}
+		select {
+		case msgs <- msg:
+		case <-obsCtx.Done():
+		}
+	})
+	return msgs, func() {
+		cancel()
+		obs.Remove()
+	}
+}
+
+// connDone returns a channel closed once the connection ends, which is never
+// closed when the commands aren't bound to a client.
+func (c *Commands) connDone() <-chan struct{} {
+	if c.done == nil {
+		return nil
+	}
+	return c.done()
+}
@@ -0,0 +1,153 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandWait(t *testing.T) {
+	require := require.New(t)
+	c, srv := connectTestClient(require)
+	defer srv.close()
+
+	ctx := context.Background()
+	errs := make(chan error, 1)
+	join := func(channel string, key ...string) {
+		go func() {
+			errs <- c.Join(ctx, channel, key...)
+		}()
+	}
+
+	// Only our own JOIN completes the command
+	join("#chatto")
+	srv.expect("JOIN #chatto")
+	srv.send(
+		":alice!~alice@alice.host JOIN #chatto",
+		":irc.test 474 chatto #go :Cannot join channel (+b)",
+		":chatto!~chatto@chatto.host JOIN #Chatto",
+	)
+	require.Nil(<-errs)
+
+	join("#go")
+	srv.expect("JOIN #go")
+	srv.send(":irc.test 474 chatto #go :Cannot join channel (+b)")
+	err := <-errs
+	require.True(errors.Is(err, ErrBannedFromChan))
+	var replyErr *ReplyError
+	require.True(errors.As(err, &replyErr))
+	require.Equal(ERR_BANNEDFROMCHAN, replyErr.Code)
+	require.Equal("Cannot join channel (+b)", replyErr.Message)
+
+	join("#full", "key")
+	srv.expect("JOIN #full key")
+	srv.send(":irc.test 471 chatto #full :Cannot join channel (+l)")
+	require.True(errors.Is(<-errs, ErrChannelIsFull))
+	require.NotContains(c.Channels(), "#full")
+
+	join("#secret")
+	srv.expect("JOIN #secret")
+	srv.send(":irc.test 473 chatto #secret :Cannot join channel (+i)")
+	require.True(errors.Is(<-errs, ErrInviteOnlyChan))
+
+	join("nochannel")
+	srv.expect("JOIN nochannel")
+	srv.send(":irc.test 403 chatto nochannel :No such channel")
+	require.True(errors.Is(<-errs, ErrNoSuchChannel))
+
+	// Errors about the command itself
+	go func() {
+		_, err := c.CommandWait(ctx, KICK, "#chatto")
+		errs <- err
+	}()
+	srv.expect("KICK #chatto")
+	srv.send(":irc.test 461 chatto KICK :Not enough parameters")
+	require.True(errors.Is(<-errs, ErrNeedMoreParams))
+
+	// Standard replies
+	go func() {
+		_, err := c.CommandWait(ctx, SETNAME, ":Chatto Bot")
+		errs <- err
+	}()
+	srv.expect("SETNAME :Chatto Bot")
+	srv.send(":irc.test FAIL SETNAME CANNOT_CHANGE_REALNAME :Realname is locked")
+	err = <-errs
+	require.True(errors.As(err, &replyErr))
+	require.Equal("CANNOT_CHANGE_REALNAME", replyErr.Code)
+
+	require.Nil(closeClient(c, srv))
+}
+
+func TestCommandWaitLabeled(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto", Caps: []string{"batch", "labeled-response"}})
+	err := connectClient(c, conn, func() {
+		srv.expect("CAP LS 302")
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(":irc.test CAP * LS :batch labeled-response")
+		srv.expect("CAP REQ :batch labeled-response")
+		srv.send(":irc.test CAP * ACK :batch labeled-response")
+		srv.expect("CAP END")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+
+	ctx := context.Background()
+	type result struct {
+		msg Message
+		err error
+	}
+	results := make(chan result, 1)
+	wait := func(cmd string, args ...string) {
+		go func() {
+			msg, err := c.CommandWait(ctx, cmd, args...)
+			results <- result{msg, err}
+		}()
+	}
+
+	// Replies are matched by label, even when they don't mention the target
+	wait(JOIN, "#chatto")
+	srv.expect("@label=1 JOIN #chatto")
+	srv.send(
+		":chatto!~chatto@chatto.host JOIN #other",
+		"@label=1 :irc.test BATCH +b1 labeled-response",
+		"@batch=b1 :chatto!~chatto@chatto.host JOIN #chatto",
+		"@batch=b1 :irc.test 353 chatto = #chatto :@chatto",
+		"@batch=b1 :irc.test 366 chatto #chatto :End of /NAMES list.",
+		":irc.test BATCH -b1",
+	)
+	res := <-results
+	require.Nil(res.err)
+	require.Equal(JOIN, res.msg.Cmd)
+	require.Equal("#chatto", res.msg.Args[0])
+
+	wait(AWAY)
+	srv.expect("@label=2 AWAY")
+	srv.send("@label=2 :irc.test 305 chatto :You are no longer marked as being away")
+	res = <-results
+	require.Nil(res.err)
+	require.Equal(RPL_UNAWAY, res.msg.Cmd)
+
+	wait(MODE, "#chatto", "+m")
+	srv.expect("@label=3 MODE #chatto +m")
+	srv.send("@label=3 :irc.test ACK")
+	res = <-results
+	require.Nil(res.err)
+	require.Equal(ACK, res.msg.Cmd)
+
+	wait(JOIN, "#go")
+	srv.expect("@label=4 JOIN #go")
+	srv.send("@label=4 :irc.test 475 chatto #go :Cannot join channel (+k)")
+	require.True(errors.Is((<-results).err, ErrBadChannelKey))
+
+	require.Nil(closeClient(c, srv))
+}
@@ -0,0 +1,266 @@
+package irc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+const (
+	SASL_PLAIN         = "PLAIN"
+	SASL_EXTERNAL      = "EXTERNAL"
+	SASL_SCRAM_SHA_256 = "SCRAM-SHA-256"
+)
+
+// Maximum length of a single AUTHENTICATE payload chunk
+const saslChunkSize = 400
+
+var (
+	ErrSASLUnavailable = errors.New("SASL is not supported by the server")
+	ErrSASLFailed      = errors.New("SASL authentication failed")
+	ErrSASLTooLong     = errors.New("SASL message too long")
+	ErrSASLAborted     = errors.New("SASL authentication aborted")
+	ErrSASLAlready     = errors.New("already authenticated")
+	ErrSASLMechanisms  = errors.New("SASL mechanism not supported")
+	ErrNickLocked      = errors.New("nickname is locked")
+	ErrSCRAMInvalid    = errors.New("invalid SCRAM server message")
+)
+
+var saslErrors = map[string]error{
+	ERR_NICKLOCKED:  ErrNickLocked,
+	ERR_SASLFAIL:    ErrSASLFailed,
+	ERR_SASLTOOLONG: ErrSASLTooLong,
+	ERR_SASLABORTED: ErrSASLAborted,
+	ERR_SASLALREADY: ErrSASLAlready,
+	RPL_SASLMECHS:   ErrSASLMechanisms,
+}
+
+type SASLConfig struct {
+	// Mechanism defaults to PLAIN
+	Mechanism string
+	Username  string
+	Password  string
+}
+
+// SASLError is returned by Client.Connect when the authentication fails.
+type SASLError struct {
+	Code       string
+	Message    string
+	Mechanisms []string
+}
+
+func (e *SASLError) Error() string {
+	msg := fmt.Sprintf("sasl %s: %s", e.Code, e.Message)
+	if len(e.Mechanisms) > 0 {
+		msg += " (available: " + strings.Join(e.Mechanisms, ",") + ")"
+	}
+	return msg
+}
+
+func (e *SASLError) Unwrap() error {
+	return saslErrors[e.Code]
+}
+
+type saslMechanism interface {
+	// Next returns the response for the given server challenge
+	Next(challenge []byte) ([]byte, error)
+}
+
+func newSASLMechanism(cfg *SASLConfig) (saslMechanism, error) {
+	switch cfg.Mechanism {
+	case SASL_PLAIN:
+		return &saslPlain{cfg.Username, cfg.Password}, nil
+	case SASL_EXTERNAL:
+		return &saslExternal{}, nil
+	case SASL_SCRAM_SHA_256:
+		return newSCRAM(sha256.New, cfg.Username, cfg.Password)
+	}
+	return nil, &SASLError{
+		Code:    RPL_SASLMECHS,
+		Message: "unknown mechanism " + cfg.Mechanism,
+	}
+}
+
+type saslPlain struct {
+	username, password string
+}
+
+func (m *saslPlain) Next([]byte) ([]byte, error) {
+	return []byte(m.username + "\x00" + m.username + "\x00" + m.password), nil
+}
+
+type saslExternal struct{}
+
+func (m *saslExternal) Next([]byte) ([]byte, error) {
+	return []byte{}, nil
+}
+
+// scram implements the client side of RFC 5802 for the given hash function.
+type scram struct {
+	hash     func() hash.Hash
+	username string
+	password string
+	nonce    string
+
+	step            int
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newSCRAM(h func() hash.Hash, username, password string) (*scram, error) {
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return &scram{
+		hash:     h,
+		username: username,
+		password: password,
+		nonce:    base64.RawStdEncoding.EncodeToString(buf),
+	}, nil
+}
+
+func (m *scram) Next(challenge []byte) ([]byte, error) {
+	m.step++
+	switch m.step {
+	case 1:
+		return m.clientFirst(), nil
+	case 2:
+		return m.clientFinal(challenge)
+	case 3:
+		return m.verifyServerFinal(challenge)
+	}
+	return nil, ErrSCRAMInvalid
+}
+
+func (m *scram) clientFirst() []byte {
+	name := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(m.username)
+	m.clientFirstBare = "n=" + name + ",r=" + m.nonce
+	return []byte("n,," + m.clientFirstBare)
+}
+
+func (m *scram) clientFinal(serverFirst []byte) ([]byte, error) {
+	attrs := parseSCRAMAttributes(string(serverFirst))
+	nonce, salt64, iter := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(nonce, m.nonce) || len(nonce) == len(m.nonce) {
+		return nil, ErrSCRAMInvalid
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return nil, ErrSCRAMInvalid
+	}
+	iterations, err := strconv.Atoi(iter)
+	if err != nil || iterations <= 0 {
+		return nil, ErrSCRAMInvalid
+	}
+
+	salted := pbkdf2(m.hash, []byte(m.password), salt, iterations)
+	clientKey := m.hmac(salted, []byte("Client Key"))
+	h := m.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte("n,,")) + ",r=" + nonce
+	authMessage := []byte(m.clientFirstBare + "," + string(serverFirst) + "," + withoutProof)
+	clientSignature := m.hmac(storedKey, authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	m.serverSignature = m.hmac(m.hmac(salted, []byte("Server Key")), authMessage)
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+func (m *scram) verifyServerFinal(serverFinal []byte) ([]byte, error) {
+	attrs := parseSCRAMAttributes(string(serverFinal))
+	if e, ok := attrs["e"]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrSCRAMInvalid, e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(signature, m.serverSignature) {
+		return nil, ErrSCRAMInvalid
+	}
+	return []byte{}, nil
+}
+
+func (m *scram) hmac(key, data []byte) []byte {
+	mac := hmac.New(m.hash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func parseSCRAMAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		if len(part) >= 2 && part[1] == '=' {
+			attrs[part[:1]] = part[2:]
+		}
+	}
+	return attrs
+}
+
+// pbkdf2 derives a key of the hash size as defined in RFC 8018.
+func pbkdf2(h func() hash.Hash, password, salt []byte, iterations int) []byte {
+	mac := hmac.New(h, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	result := make([]byte, len(u))
+	copy(result, u)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+// encodeSASLPayload splits the payload into AUTHENTICATE parameters.
+func encodeSASLPayload(payload []byte) []string {
+	if len(payload) <= 0 {
+		return []string{"+"}
+	}
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	result := make([]string, 0, len(encoded)/saslChunkSize+1)
+	for len(encoded) >= saslChunkSize {
+		result = append(result, encoded[:saslChunkSize])
+		encoded = encoded[saslChunkSize:]
+	}
+	// A payload of an exact multiple of the chunk size is terminated by "+"
+	if encoded == "" {
+		encoded = "+"
+	}
+	return append(result, encoded)
+}
+
+// saslDecoder accumulates the chunks of an AUTHENTICATE challenge.
+type saslDecoder struct {
+	buf bytes.Buffer
+}
+
+// feed returns true along with the decoded challenge once it is complete.
+func (d *saslDecoder) feed(chunk string) ([]byte, bool, error) {
+	if chunk != "+" {
+		d.buf.WriteString(chunk)
+	}
+	if len(chunk) == saslChunkSize {
+		return nil, false, nil
+	}
+	defer d.buf.Reset()
+	if d.buf.Len() <= 0 {
+		return []byte{}, true, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(d.buf.String())
+	return decoded, true, err
+}
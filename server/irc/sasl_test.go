@@ -0,0 +1,166 @@
+package irc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSASLPlain(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{
+		Nick: "chatto",
+		SASL: &SASLConfig{
+			Username: "chatto",
+			Password: "hunter2",
+		},
+	})
+	err := connectClient(c, conn, func() {
+		srv.expect("CAP LS 302")
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(":irc.test CAP * LS :sasl=PLAIN,EXTERNAL")
+		srv.expect("CAP REQ :sasl")
+		srv.send(":irc.test CAP * ACK :sasl")
+		srv.expect("AUTHENTICATE PLAIN")
+		srv.send("AUTHENTICATE +")
+		srv.expect("AUTHENTICATE " + base64.StdEncoding.EncodeToString([]byte("chatto\x00chatto\x00hunter2")))
+		srv.send(
+			":irc.test 900 chatto chatto!chatto@host chatto :You are now logged in as chatto",
+			":irc.test 903 chatto :SASL authentication successful",
+		)
+		srv.expect("CAP END")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+	require.Nil(closeClient(c, srv))
+}
+
+func TestSASLFailure(t *testing.T) {
+	require := require.New(t)
+
+	// Authentication rejected by the server
+	{
+		srv, conn := newTestServer(require)
+		c := NewClient(Config{
+			Nick: "chatto",
+			SASL: &SASLConfig{Username: "chatto", Password: "wrong"},
+		})
+		err := connectClient(c, conn, func() {
+			srv.expect("CAP LS 302")
+			srv.expect("NICK chatto")
+			srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+			srv.send(":irc.test CAP * LS :sasl")
+			srv.expect("CAP REQ :sasl")
+			srv.send(":irc.test CAP * ACK :sasl")
+			srv.expect("AUTHENTICATE PLAIN")
+			srv.send("AUTHENTICATE +")
+			srv.readLine()
+			srv.send(
+				":irc.test 908 chatto PLAIN,EXTERNAL :are available SASL mechanisms",
+				":irc.test 904 chatto :SASL authentication failed",
+			)
+		})
+		srv.close()
+		require.True(errors.Is(err, ErrSASLFailed))
+		var saslErr *SASLError
+		require.True(errors.As(err, &saslErr))
+		require.Equal([]string{"PLAIN", "EXTERNAL"}, saslErr.Mechanisms)
+		require.False(c.Connected())
+	}
+
+	// Mechanism not advertised by the server
+	{
+		srv, conn := newTestServer(require)
+		c := NewClient(Config{
+			Nick: "chatto",
+			SASL: &SASLConfig{Mechanism: SASL_SCRAM_SHA_256},
+		})
+		err := connectClient(c, conn, func() {
+			srv.expect("CAP LS 302")
+			srv.expect("NICK chatto")
+			srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+			srv.send(":irc.test CAP * LS :sasl=PLAIN")
+			srv.expect("CAP REQ :sasl")
+			srv.send(":irc.test CAP * ACK :sasl")
+		})
+		srv.close()
+		require.True(errors.Is(err, ErrSASLMechanisms))
+	}
+
+	// Server without SASL support
+	{
+		srv, conn := newTestServer(require)
+		c := NewClient(Config{
+			Nick: "chatto",
+			SASL: &SASLConfig{Mechanism: SASL_EXTERNAL},
+		})
+		err := connectClient(c, conn, func() {
+			srv.expect("CAP LS 302")
+			srv.expect("NICK chatto")
+			srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+			srv.send(":irc.test CAP * LS :multi-prefix")
+		})
+		srv.close()
+		require.Equal(ErrSASLUnavailable, err)
+	}
+}
+
+func TestSCRAM(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Test vectors from RFC 7677
+	m, err := newSCRAM(sha256.New, "user", "pencil")
+	require.Nil(err)
+	m.nonce = "rOprNGfwEbeRWgbNEkqO"
+
+	first, err := m.Next([]byte{})
+	require.Nil(err)
+	assert.Equal("n,,n=user,r=rOprNGfwEbeRWgbNEkqO", string(first))
+
+	final, err := m.Next([]byte("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"))
+	require.Nil(err)
+	assert.Equal("c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", string(final))
+
+	last, err := m.Next([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="))
+	require.Nil(err)
+	assert.Empty(last)
+
+	// Tampered server signature
+	m.step = 2
+	_, err = m.Next([]byte("v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="))
+	assert.Equal(ErrSCRAMInvalid, err)
+}
+
+func TestSASLPayload(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"+"}, encodeSASLPayload(nil))
+
+	// 300 bytes encode to exactly 400 base64 characters
+	payload := make([]byte, 300)
+	chunks := encodeSASLPayload(payload)
+	assert.Len(chunks, 2)
+	assert.Len(chunks[0], saslChunkSize)
+	assert.Equal("+", chunks[1])
+
+	var d saslDecoder
+	_, done, err := d.feed(chunks[0])
+	assert.Nil(err)
+	assert.False(done)
+	decoded, done, err := d.feed(chunks[1])
+	assert.Nil(err)
+	assert.True(done)
+	assert.Equal(payload, decoded)
+}
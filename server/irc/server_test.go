@@ -0,0 +1,70 @@
+package irc
+
+import (
+	utesting "chatto/util/testing"
+	"context"
+	"net"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testServer scripts the server side of a connection, on a raw session of
+// the fake IRC server.
+type testServer struct {
+	require *require.Assertions
+	session *utesting.IRCSession
+}
+
+func newTestServer(require *require.Assertions) (*testServer, net.Conn) {
+	client, server := net.Pipe()
+	return newTestServerConn(require, server), client
+}
+
+func newTestServerConn(require *require.Assertions, conn net.Conn) *testServer {
+	srv := utesting.NewIRCServer()
+	srv.Raw = true
+	return &testServer{
+		require: require,
+		session: srv.Serve(conn),
+	}
+}
+
+func (s *testServer) readLine() string {
+	msg, err := s.session.Next(time.Second)
+	s.require.Nil(err)
+	return msg.Raw
+}
+
+func (s *testServer) expect(expected string) {
+	s.require.Equal(expected, s.readLine())
+}
+
+func (s *testServer) send(lines ...string) {
+	s.session.Send(lines...)
+}
+
+func (s *testServer) close() {
+	s.session.Close()
+}
+
+// connectClient runs the client registration against the scripted server.
+func connectClient(c *Client, conn net.Conn, script func()) error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c.Connect(context.Background(), conn)
+	}()
+	script()
+	return <-ch
+}
+
+// closeClient closes the client, answering its QUIT by closing the connection.
+func closeClient(c *Client, s *testServer) error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c.Close(context.Background())
+	}()
+	s.expect(QUIT)
+	s.close()
+	return <-ch
+}
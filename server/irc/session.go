@@ -0,0 +1,82 @@
+package irc
+
+import "context"
+
+// CurrentNick returns the nick currently in use on the connection.
+func (c *Client) CurrentNick() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.nick
+}
+
+// Channels returns the joined channels along with their keys.
+func (c *Client) Channels() map[string]string {
+	result := make(map[string]string, c.channels.Len())
+	c.channels.Each(func(name string, key interface{}) bool {
+		result[name] = key.(string)
+		return true
+	})
+	return result
+}
+
+// Join joins the channel and remembers its key so it can be rejoined later.
+func (c *Client) Join(ctx context.Context, channel string, key ...string) error {
+	if err := c.Commands.Join(ctx, channel, key...); err != nil {
+		return err
+	}
+	if len(key) > 0 {
+		c.channels.Set(channel, key[0])
+	}
+	return nil
+}
+
+// Nick changes our nick, which becomes the preferred one once the server
+// accepts it.
+func (c *Client) Nick(ctx context.Context, nick string) error {
+	c.mu.Lock()
+	c.pendingNick = nick
+	c.mu.Unlock()
+	return c.Commands.Nick(ctx, nick)
+}
+
+func (c *Client) isSelf(nick string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return nick != "" && c.isupport.EqualFold(nick, c.nick)
+}
+
+func (c *Client) trackJoin(channel string) {
+	if !c.channels.Has(channel) {
+		c.channels.Set(channel, "")
+	}
+}
+
+func (c *Client) trackPart(channel string) {
+	c.channels.Delete(channel)
+}
+
+func (c *Client) trackNick(nick string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nick = nick
+	// The nicks forced by the server don't replace the preferred one
+	if c.pendingNick != "" && c.isupport.EqualFold(c.pendingNick, nick) {
+		c.prefNick = nick
+		c.pendingNick = ""
+	}
+}
+
+func (c *Client) preferredNick() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.prefNick
+}
+
+// resetSession forgets the session state after the connection is closed on purpose.
+func (c *Client) resetSession() {
+	c.channels.Clear()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prefNick = c.cfg.Nick
+	c.pendingNick = ""
+}
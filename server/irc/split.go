@@ -0,0 +1,271 @@
+package irc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxLineLength = 512
+	// Longest hostname allowed, used when our own host isn't known yet
+	maxHostLength = 63
+)
+
+// Formatting control codes which toggle a style on and off
+var toggleCodes = []byte{'\x02', '\x1D', '\x1F', '\x1E', '\x11', '\x16'}
+
+// SplitMessage splits the text into lines of at most maxLen bytes each.
+// Newlines always start a new line, the lines without any text being dropped
+// since they can't be sent. Runes and formatting codes are never cut in half
+// and the formatting in effect is carried over to the following line when it
+// fits. When words is set, lines are preferably broken at spaces.
+func SplitMessage(text string, maxLen int, words bool) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	result := make([]string, 0, 1)
+	for _, line := range strings.Split(text, "\n") {
+		result = append(result, splitLine(line, maxLen, words)...)
+	}
+	return result
+}
+
+func splitLine(line string, maxLen int, words bool) []string {
+	if len(line) <= maxLen {
+		if !hasText(line) {
+			return nil
+		}
+		return []string{line}
+	}
+
+	result := make([]string, 0, len(line)/maxLen+1)
+	state := formatState{}
+	var sb strings.Builder
+	// Position of the last space in the builder and the state at that point
+	lastSpace, spaceState := -1, formatState{}
+	// Formatting restored at the beginning of the current line
+	carried := 0
+
+	for i := 0; i < len(line); {
+		n := formatAtomLength(line[i:])
+		atom := line[i : i+n]
+		if n > maxLen && isFormatCode(atom[0]) {
+			// The formatting codes which can't fit in any line are dropped
+			i += n
+			continue
+		}
+		if sb.Len()+n > maxLen && sb.Len() > carried {
+			current := sb.String()
+			rest := ""
+			lineState := state
+			if words && atom == " " {
+				// Break right at the overflowing space, which is dropped
+				n = 0
+			} else if words && lastSpace > carried && sb.Len()-lastSpace-1+n <= maxLen {
+				current, rest = current[:lastSpace], current[lastSpace+1:]
+				lineState = spaceState
+			}
+			if hasText(current) {
+				result = append(result, current)
+			}
+
+			prefix := lineState.codes()
+			if len(prefix)+len(rest)+n > maxLen {
+				// Drop the carried formatting rather than exceeding the limit
+				prefix = ""
+				state = scanFormat(rest)
+			}
+			sb.Reset()
+			sb.WriteString(prefix)
+			sb.WriteString(rest)
+			carried = len(prefix)
+			lastSpace = -1
+			if n == 0 {
+				i++
+				continue
+			}
+		} else if sb.Len()+n > maxLen {
+			// Only the carried formatting is left, which doesn't fit either
+			sb.Reset()
+			carried = 0
+			state = formatState{}
+		}
+		if atom == " " {
+			lastSpace, spaceState = sb.Len(), state
+		}
+		state.apply(atom)
+		sb.WriteString(atom)
+		i += n
+	}
+	if sb.Len() > carried && hasText(sb.String()) {
+		result = append(result, sb.String())
+	}
+	return result
+}
+
+// hasText reports whether the line has anything besides formatting codes.
+func hasText(line string) bool {
+	for i := 0; i < len(line); i += formatAtomLength(line[i:]) {
+		if !isFormatCode(line[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanFormat returns the formatting in effect at the end of the text.
+func scanFormat(text string) formatState {
+	state := formatState{}
+	for i := 0; i < len(text); {
+		n := formatAtomLength(text[i:])
+		state.apply(text[i : i+n])
+		i += n
+	}
+	return state
+}
+
+func isFormatCode(c byte) bool {
+	switch c {
+	case '\x03', '\x04', '\x0F':
+		return true
+	}
+	for _, code := range toggleCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// formatAtomLength returns the length of the rune or formatting code at the
+// beginning of the string.
+func formatAtomLength(s string) int {
+	switch s[0] {
+	case '\x03':
+		n := 1 + countDigits(s[1:], 2)
+		if n > 1 && n+1 < len(s) && s[n] == ',' {
+			if digits := countDigits(s[n+1:], 2); digits > 0 {
+				n += 1 + digits
+			}
+		}
+		return n
+	case '\x04':
+		n := 1 + countHex(s[1:], 6)
+		if n == 7 && n+1 < len(s) && s[n] == ',' {
+			if digits := countHex(s[n+1:], 6); digits == 6 {
+				n += 7
+			}
+		}
+		return n
+	}
+	_, n := utf8.DecodeRuneInString(s)
+	return n
+}
+
+func countDigits(s string, max int) int {
+	n := 0
+	for n < max && n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+func countHex(s string, max int) int {
+	n := 0
+	for n < max && n < len(s) && isHexDigit(s[n]) {
+		n++
+	}
+	return n
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// formatState tracks the formatting in effect while scanning a line.
+type formatState struct {
+	// toggles holds one bit for each of the toggleCodes
+	toggles uint8
+	color   string
+}
+
+func (f *formatState) apply(atom string) {
+	switch atom[0] {
+	case '\x0F':
+		f.toggles = 0
+		f.color = ""
+	case '\x03', '\x04':
+		if len(atom) > 1 {
+			f.color = atom
+		} else {
+			f.color = ""
+		}
+	default:
+		for i, code := range toggleCodes {
+			if atom[0] == code {
+				f.toggles ^= 1 << i
+			}
+		}
+	}
+}
+
+// codes returns the formatting codes which restore the state.
+func (f formatState) codes() string {
+	var sb strings.Builder
+	for i, code := range toggleCodes {
+		if f.toggles&(1<<i) != 0 {
+			sb.WriteByte(code)
+		}
+	}
+	sb.WriteString(f.color)
+	return sb.String()
+}
+
+// maxMessageLength returns the room left for the text of a message to the
+// target, given how other clients see our prefix.
+func (c *Commands) maxMessageLength(cmd string, target string) int {
+	lineLen, prefix := maxLineLength, 0
+	if c.lineLimits != nil {
+		lineLen, prefix = c.lineLimits()
+	}
+	// ":<prefix> <cmd> <target> :<text>\r\n"
+	n := lineLen - 2 - (prefix + 2) - (len(cmd) + len(target) + 3)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// lineLimits returns the line length allowed by the server and the length of
+// our nick!user@host as relayed by the server.
+func (c *Client) lineLimits() (int, int) {
+	lineLen := c.isupport.LineLen()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	user, host := c.user, c.host
+	if user == "" {
+		// The server may prefix the ident with a tilde
+		user = "~" + c.cfg.Ident
+	}
+	if host == "" {
+		host = strings.Repeat(" ", maxHostLength)
+	}
+	return lineLen, len(c.nick) + len(user) + len(host) + 2
+}
+
+// trackSource records our user and host from a prefix like nick!user@host.
+func (c *Client) trackSource(src string) {
+	nidx, iidx := strings.Index(src, "!"), strings.Index(src, "@")
+	if nidx == -1 || iidx < nidx {
+		return
+	}
+	c.trackHost(src[nidx+1:iidx], src[iidx+1:])
+}
+
+func (c *Client) trackHost(user string, host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if user != "" {
+		c.user = user
+	}
+	c.host = host
+}
@@ -0,0 +1,153 @@
+package irc
+
+import (
+	"chatto/irc/format"
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	// Short messages are left alone, newlines always split
+	assert.Equal([]string{"Hello, world!"}, SplitMessage("Hello, world!", 20, false))
+	assert.Equal([]string{"a", "b", "c"}, SplitMessage("a\r\nb\n\rc", 20, false))
+	// Empty lines are dropped
+	assert.Empty(SplitMessage("", 20, false))
+	assert.Equal([]string{"a", "b"}, SplitMessage("\na\n\n\nb\n", 20, false))
+
+	// Hard split by bytes
+	assert.Equal([]string{"abcd", "efgh", "ij"}, SplitMessage("abcdefghij", 4, false))
+
+	// Runes are never cut in half
+	{
+		lines := SplitMessage(strings.Repeat("日本語", 10), 10, false)
+		for _, line := range lines {
+			assert.True(utf8.ValidString(line))
+			assert.LessOrEqual(len(line), 10)
+		}
+		assert.Equal(strings.Repeat("日本語", 10), strings.Join(lines, ""))
+	}
+
+	// Word boundaries are preferred when enabled
+	assert.Equal(
+		[]string{"the quick", "brown fox", "jumps"},
+		SplitMessage("the quick brown fox jumps", 10, true),
+	)
+	assert.Equal(
+		[]string{"the quick ", "brown fox ", "jumps"},
+		SplitMessage("the quick brown fox jumps", 10, false),
+	)
+	// Words longer than a line still get split
+	assert.Equal(
+		[]string{"a", "bcdefghijk", "lm"},
+		SplitMessage("a bcdefghijklm", 10, true),
+	)
+
+	// Color codes are kept whole and carried to the next line
+	assert.Equal(
+		[]string{"\x0304,12ab", "\x0304,12cd"},
+		SplitMessage("\x0304,12abcd", 8, false),
+	)
+	assert.Equal(
+		[]string{"\x02x\x02 \x1Dyy", "\x1Dzz"},
+		SplitMessage("\x02x\x02 \x1Dyy zz", 7, true),
+	)
+}
+
+func TestSplitMessageLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	texts := []string{
+		"\x02\x0312,04\x04FF00FFhello world",
+		"\x02\x1D\x1F\x1E\x11\x16\x0312,04formatted text",
+		"\x04FF00FF,00FF00a\x0Fb c\x02d e",
+		"\x02\x0312,04\x04FF00FF",
+		"plain words of text",
+	}
+	for _, text := range texts {
+		for maxLen := 4; maxLen <= 12; maxLen++ {
+			for _, words := range []bool{false, true} {
+				lines := SplitMessage(text, maxLen, words)
+				for _, line := range lines {
+					assert.LessOrEqual(len(line), maxLen, "%q at %d", line, maxLen)
+					assert.NotEmpty(format.Strip(line), "%q at %d", line, maxLen)
+				}
+				expected, actual := format.Strip(text), format.Strip(strings.Join(lines, ""))
+				if words {
+					// The spaces at the line breaks are dropped
+					expected, actual = strings.ReplaceAll(expected, " ", ""), strings.ReplaceAll(actual, " ", "")
+				}
+				assert.Equal(expected, actual, "%q at %d", text, maxLen)
+			}
+		}
+	}
+
+	// The formatting which doesn't fit along with the text is dropped
+	assert.Equal(
+		[]string{"\x02\x0312,04a", "\x02\x0312,04b"},
+		SplitMessage("\x02\x0312,04ab", 8, false),
+	)
+	assert.Equal([]string{"abcdef"}, SplitMessage("\x02\x0312,04abcdef", 7, false))
+	// Lines made only of formatting codes aren't sent
+	assert.Empty(SplitMessage("\x02\x0312,04\x04FF00FF", 11, false))
+}
+
+func TestPrivmsgSplit(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto", SplitWords: true})
+	err := connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome to the network chatto!~chatto@host.test",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+
+	word := strings.Repeat("x", 99)
+	text := strings.TrimSpace(strings.Repeat(word+" ", 10))
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c.Privmsg(context.Background(), "#chatto", text+"\nbye")
+	}()
+
+	// Each relayed line must fit in 512 bytes with our prefix
+	prefix := ":chatto!~chatto@host.test "
+	received := make([]string, 0)
+	for {
+		line := srv.readLine()
+		require.LessOrEqual(len(prefix)+len(line)+2, 512)
+		require.True(strings.HasPrefix(line, "PRIVMSG #chatto :"))
+		received = append(received, strings.TrimPrefix(line, "PRIVMSG #chatto :"))
+		if received[len(received)-1] == "bye" {
+			break
+		}
+	}
+	require.Nil(<-ch)
+	require.Len(received, 4)
+	require.Equal(text, strings.Join(received[:3], " "))
+
+	// Blank lines aren't sent, which would be an empty message
+	go func() {
+		ch <- c.Privmsg(context.Background(), "#chatto", "hi\n\nthere\n")
+	}()
+	srv.expect("PRIVMSG #chatto :hi")
+	srv.expect("PRIVMSG #chatto :there")
+	require.Nil(<-ch)
+	go func() {
+		ch <- c.Action(context.Background(), "#chatto", "")
+	}()
+	srv.expect("PRIVMSG #chatto :\x01ACTION\x01")
+	require.Nil(<-ch)
+	require.Nil(closeClient(c, srv))
+}
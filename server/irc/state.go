@@ -0,0 +1,511 @@
+package irc
+
+import (
+	"chatto/irc/casemapping"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Member struct {
+	Nick string
+	User string
+	Host string
+	// Modes holds the membership modes ordered by rank, e.g. "ov"
+	Modes string
+}
+
+type Topic struct {
+	Text  string
+	SetBy string
+	SetAt time.Time
+}
+
+// Channel holds the state of a joined channel. It is safe for concurrent use.
+type Channel struct {
+	mu       sync.RWMutex
+	name     string
+	isupport *ISupport
+
+	topic Topic
+	modes map[byte]string
+	lists map[byte][]string
+	// members holds a *Member for each nick in the channel
+	members *casemapping.Map
+	// Members received from RPL_NAMREPLY until RPL_ENDOFNAMES
+	names *casemapping.Map
+}
+
+func newChannel(name string, isupport *ISupport) *Channel {
+	return &Channel{
+		name:     name,
+		isupport: isupport,
+		modes:    make(map[byte]string),
+		lists:    make(map[byte][]string),
+		members:  casemapping.NewMap(isupport.Mapping()),
+	}
+}
+
+func (ch *Channel) Name() string {
+	return ch.name
+}
+
+// Members returns the channel members sorted by nick.
+func (ch *Channel) Members() []Member {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	result := make([]Member, 0, ch.members.Len())
+	ch.members.Each(func(_ string, member interface{}) bool {
+		result = append(result, *member.(*Member))
+		return true
+	})
+	return result
+}
+
+func (ch *Channel) Member(nick string) (Member, bool) {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	if member, ok := ch.member(nick); ok {
+		return *member, true
+	}
+	return Member{}, false
+}
+
+func (ch *Channel) member(nick string) (*Member, bool) {
+	if member, ok := ch.members.Get(nick); ok {
+		return member.(*Member), true
+	}
+	return nil, false
+}
+
+func (ch *Channel) HasMember(nick string) bool {
+	_, ok := ch.Member(nick)
+	return ok
+}
+
+// HasMode reports whether the member has the membership mode, like 'v'.
+func (ch *Channel) HasMode(nick string, mode byte) bool {
+	member, ok := ch.Member(nick)
+	return ok && strings.IndexByte(member.Modes, mode) != -1
+}
+
+// IsOp reports whether the member is a channel operator or ranked higher.
+func (ch *Channel) IsOp(nick string) bool {
+	return ch.hasRank(nick, 'o')
+}
+
+// IsVoice reports whether the member is voiced or ranked higher.
+func (ch *Channel) IsVoice(nick string) bool {
+	return ch.hasRank(nick, 'v')
+}
+
+func (ch *Channel) hasRank(nick string, mode byte) bool {
+	member, ok := ch.Member(nick)
+	if !ok || member.Modes == "" {
+		return false
+	}
+	modes, _ := ch.isupport.Prefix()
+	rank := strings.IndexByte(modes, mode)
+	if rank == -1 {
+		return strings.IndexByte(member.Modes, mode) != -1
+	}
+	// The modes missing from PREFIX, which may have changed since, don't rank
+	for i := 0; i < len(member.Modes); i++ {
+		if r := strings.IndexByte(modes, member.Modes[i]); r != -1 && r <= rank {
+			return true
+		}
+	}
+	return false
+}
+
+func (ch *Channel) Topic() Topic {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.topic
+}
+
+// Modes returns the channel modes along with their parameters.
+func (ch *Channel) Modes() map[byte]string {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	result := make(map[byte]string, len(ch.modes))
+	for mode, param := range ch.modes {
+		result[mode] = param
+	}
+	return result
+}
+
+// Mode returns the parameter of the channel mode and whether it is set.
+func (ch *Channel) Mode(mode byte) (string, bool) {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	param, ok := ch.modes[mode]
+	return param, ok
+}
+
+// List returns the entries of a list mode seen so far, like bans for 'b'.
+func (ch *Channel) List(mode byte) []string {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return append([]string{}, ch.lists[mode]...)
+}
+
+func (ch *Channel) addMember(src string) {
+	nick, user, host := splitSource(src)
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if member, ok := ch.member(nick); ok {
+		member.User, member.Host = user, host
+		return
+	}
+	ch.members.Set(nick, &Member{Nick: nick, User: user, Host: host})
+}
+
+func (ch *Channel) removeMember(nick string) bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.members.Delete(nick)
+}
+
+func (ch *Channel) renameMember(from string, to string) bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	member, ok := ch.member(from)
+	if !ok {
+		return false
+	}
+	member.Nick = to
+	return ch.members.Rename(from, to)
+}
+
+// addNames adds the members of a RPL_NAMREPLY, which replace the current
+// members once RPL_ENDOFNAMES is received.
+func (ch *Channel) addNames(names string) {
+	members := parseNames(ch.isupport, names)
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.names == nil {
+		ch.names = casemapping.NewMap(ch.members.Mapping())
+	}
+	for i := range members {
+		ch.names.Set(members[i].Nick, &members[i])
+	}
+}
+
+// parseNames parses the names of a RPL_NAMREPLY, which may have several
+// membership prefixes with multi-prefix and full masks with userhost-in-names.
+func parseNames(isupport *ISupport, names string) []Member {
+	_, prefixes := isupport.Prefix()
+	fields := strings.Fields(names)
+	members := make([]Member, 0, len(fields))
+	for _, name := range fields {
+		idx := 0
+		for idx < len(name) && strings.IndexByte(prefixes, name[idx]) != -1 {
+			idx++
+		}
+		member := Member{}
+		for i := 0; i < idx; i++ {
+			mode, _ := isupport.PrefixMode(name[i])
+			member.Modes = addMemberMode(isupport, member.Modes, mode)
+		}
+		member.Nick, member.User, member.Host = splitSource(name[idx:])
+		members = append(members, member)
+	}
+	return members
+}
+
+func (ch *Channel) endNames() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.names == nil {
+		return
+	}
+	ch.members = ch.names
+	ch.names = nil
+}
+
+func (ch *Channel) setTopic(text string, setBy string, setAt time.Time) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.topic.Text = text
+	if setBy != "" {
+		ch.topic.SetBy = setBy
+		ch.topic.SetAt = setAt
+	}
+}
+
+func (ch *Channel) setTopicWho(setBy string, setAt time.Time) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.topic.SetBy = setBy
+	ch.topic.SetAt = setAt
+}
+
+// applyModes applies the changes, resetting the channel modes first when the
+// changes come from RPL_CHANNELMODEIS.
+func (ch *Channel) applyModes(changes []ModeChange, reset bool) {
+	chanModes := ch.isupport.ChanModes()
+	prefixModes, _ := ch.isupport.Prefix()
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if reset {
+		ch.modes = make(map[byte]string)
+	}
+	for _, change := range changes {
+		switch {
+		case strings.IndexByte(prefixModes, change.Mode) != -1:
+			member, ok := ch.member(change.Param)
+			if !ok {
+				continue
+			}
+			if change.Add {
+				member.Modes = addMemberMode(ch.isupport, member.Modes, change.Mode)
+			} else {
+				member.Modes = strings.Replace(member.Modes, string(change.Mode), "", 1)
+			}
+		case strings.IndexByte(chanModes.A, change.Mode) != -1:
+			list := ch.lists[change.Mode]
+			filtered := list[:0]
+			for _, entry := range list {
+				if entry != change.Param {
+					filtered = append(filtered, entry)
+				}
+			}
+			if change.Add {
+				filtered = append(filtered, change.Param)
+			}
+			ch.lists[change.Mode] = filtered
+		default:
+			if change.Add {
+				ch.modes[change.Mode] = change.Param
+			} else {
+				delete(ch.modes, change.Mode)
+			}
+		}
+	}
+}
+
+// addMemberMode inserts the membership mode while keeping the modes ordered by rank.
+func addMemberMode(isupport *ISupport, modes string, mode byte) string {
+	if mode == 0 || strings.IndexByte(modes, mode) != -1 {
+		return modes
+	}
+	order, _ := isupport.Prefix()
+	rank := strings.IndexByte(order, mode)
+	for i := 0; i < len(modes); i++ {
+		if strings.IndexByte(order, modes[i]) > rank {
+			return modes[:i] + string(mode) + modes[i:]
+		}
+	}
+	return modes + string(mode)
+}
+
+func splitSource(src string) (nick string, user string, host string) {
+	nick = src
+	if idx := strings.Index(nick, "@"); idx != -1 {
+		nick, host = nick[:idx], nick[idx+1:]
+	}
+	if idx := strings.Index(nick, "!"); idx != -1 {
+		nick, user = nick[:idx], nick[idx+1:]
+	}
+	return nick, user, host
+}
+
+// channelStore holds the state of the joined channels.
+type channelStore struct {
+	isupport *ISupport
+	// channels holds a *Channel for each joined channel
+	channels *casemapping.Map
+}
+
+func newChannelStore(isupport *ISupport) *channelStore {
+	return &channelStore{
+		isupport: isupport,
+		channels: casemapping.NewMap(isupport.Mapping()),
+	}
+}
+
+func (s *channelStore) reset() {
+	s.channels.Clear()
+	s.setMapping(s.isupport.Mapping())
+}
+
+func (s *channelStore) get(name string) *Channel {
+	if ch, ok := s.channels.Get(name); ok {
+		return ch.(*Channel)
+	}
+	return nil
+}
+
+func (s *channelStore) add(name string) *Channel {
+	if ch := s.get(name); ch != nil {
+		return ch
+	}
+	ch := newChannel(name, s.isupport)
+	s.channels.Set(name, ch)
+	return ch
+}
+
+func (s *channelStore) remove(name string) {
+	s.channels.Delete(name)
+}
+
+func (s *channelStore) list() []*Channel {
+	result := make([]*Channel, 0, s.channels.Len())
+	s.channels.Each(func(_ string, ch interface{}) bool {
+		result = append(result, ch.(*Channel))
+		return true
+	})
+	return result
+}
+
+// setMapping switches the channels and their members to another casemapping.
+func (s *channelStore) setMapping(mapping casemapping.CaseMapping) {
+	s.channels.SetMapping(mapping)
+	for _, ch := range s.list() {
+		ch.mu.Lock()
+		ch.members.SetMapping(mapping)
+		ch.mu.Unlock()
+	}
+}
+
+// Channel returns the state of a joined channel, or nil when not joined.
+func (c *Client) Channel(name string) *Channel {
+	return c.state.get(name)
+}
+
+// JoinedChannels returns the state of all the joined channels.
+func (c *Client) JoinedChannels() []*Channel {
+	return c.state.list()
+}
+
+// updateState applies a message to the channel state and emits CHANNEL_UPDATED
+// for every channel which changed.
+func (c *Client) updateState(msg Message) {
+	for _, name := range c.applyState(msg) {
+		c.notifyEvent(CHANNEL_UPDATED, Event{Message: msg, Channel: name})
+	}
+}
+
+func (c *Client) applyState(msg Message) []string {
+	args := msg.Args
+	switch msg.Cmd {
+	case JOIN:
+		if len(args) < 1 {
+			return nil
+		}
+		ch := c.state.get(args[0])
+		if c.isSelf(msg.Nick) {
+			ch = c.state.add(args[0])
+		}
+		if ch == nil {
+			return nil
+		}
+		ch.addMember(msg.Src)
+		return []string{ch.name}
+	case PART:
+		if len(args) < 1 {
+			return nil
+		}
+		return c.leaveChannel(args[0], msg.Nick)
+	case KICK:
+		if len(args) < 2 {
+			return nil
+		}
+		return c.leaveChannel(args[0], args[1])
+	case QUIT:
+		changed := make([]string, 0)
+		for _, ch := range c.state.list() {
+			if ch.removeMember(msg.Nick) {
+				changed = append(changed, ch.name)
+			}
+		}
+		return changed
+	case NICK:
+		if len(args) < 1 {
+			return nil
+		}
+		changed := make([]string, 0)
+		for _, ch := range c.state.list() {
+			if ch.renameMember(msg.Nick, args[0]) {
+				changed = append(changed, ch.name)
+			}
+		}
+		return changed
+	case TOPIC:
+		if len(args) < 2 {
+			return nil
+		}
+		if ch := c.state.get(args[0]); ch != nil {
+			ch.setTopic(args[1], msg.Nick, msg.Time)
+			return []string{ch.name}
+		}
+	case MODE:
+		if len(args) < 2 {
+			return nil
+		}
+		if ch := c.state.get(args[0]); ch != nil {
+			ch.applyModes(c.isupport.ParseModeChanges(args[1:]), false)
+			return []string{ch.name}
+		}
+	case RPL_CHANNELMODEIS:
+		if len(args) < 3 {
+			return nil
+		}
+		if ch := c.state.get(args[1]); ch != nil {
+			ch.applyModes(c.isupport.ParseModeChanges(args[2:]), true)
+			return []string{ch.name}
+		}
+	case RPL_TOPIC:
+		if len(args) < 3 {
+			return nil
+		}
+		if ch := c.state.get(args[1]); ch != nil {
+			ch.setTopic(args[2], "", time.Time{})
+			return []string{ch.name}
+		}
+	case RPL_TOPICWHOTIME:
+		if len(args) < 4 {
+			return nil
+		}
+		if ch := c.state.get(args[1]); ch != nil {
+			setAt := time.Time{}
+			if ts, err := strconv.ParseInt(args[3], 10, 64); err == nil {
+				setAt = time.Unix(ts, 0)
+			}
+			ch.setTopicWho(args[2], setAt)
+			return []string{ch.name}
+		}
+	case RPL_NAMREPLY:
+		// "<client> <symbol> <channel> :<names>", the symbol is missing on some servers
+		if len(args) < 3 {
+			return nil
+		}
+		if ch := c.state.get(args[len(args)-2]); ch != nil {
+			ch.addNames(args[len(args)-1])
+		}
+	case RPL_ENDOFNAMES:
+		if len(args) < 2 {
+			return nil
+		}
+		if ch := c.state.get(args[1]); ch != nil {
+			ch.endNames()
+			return []string{ch.name}
+		}
+	}
+	return nil
+}
+
+func (c *Client) leaveChannel(channel string, nick string) []string {
+	ch := c.state.get(channel)
+	if ch == nil {
+		return nil
+	}
+	if c.isSelf(nick) {
+		c.state.remove(channel)
+	} else {
+		ch.removeMember(nick)
+	}
+	return []string{channel}
+}
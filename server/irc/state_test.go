@@ -0,0 +1,150 @@
+package irc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelStoreCaseMapping(t *testing.T) {
+	assert := assert.New(t)
+	i := newISupport()
+	s := newChannelStore(i)
+
+	ch := s.add("#Chatto")
+	assert.Equal(ch, s.get("#CHATTO"))
+	assert.Equal(ch, s.add("#chatto"))
+	assert.Equal("#Chatto", ch.Name())
+
+	ch.addNames("@Nick[m] alice")
+	ch.endNames()
+	assert.True(ch.IsOp("nick{m}"))
+	assert.True(ch.renameMember("ALICE", "Alicia"))
+	assert.True(ch.HasMember("alicia"))
+
+	// Names are refolded once the server advertises another casemapping
+	i.parse([]string{"CASEMAPPING=ascii"})
+	s.setMapping(i.Mapping())
+	assert.NotNil(s.get("#CHATTO"))
+	assert.False(ch.HasMember("nick{m}"))
+	assert.True(ch.HasMember("NICK[M]"))
+}
+
+func TestChannelRanks(t *testing.T) {
+	assert := assert.New(t)
+	i := newISupport()
+	i.parse([]string{"PREFIX=(qohv)~@%+"})
+	ch := newChannel("#chatto", i)
+	ch.addNames("~owner %half +voice bob")
+	ch.endNames()
+	assert.True(ch.IsOp("owner"))
+	assert.False(ch.IsOp("half"))
+	assert.True(ch.IsVoice("half"))
+	assert.False(ch.IsVoice("bob"))
+
+	// Modes no longer in PREFIX don't rank
+	i.parse([]string{"PREFIX=(ov)@+"})
+	assert.False(ch.IsOp("half"))
+	assert.False(ch.IsVoice("half"))
+	assert.True(ch.IsVoice("voice"))
+}
+
+func TestChannelState(t *testing.T) {
+	require := require.New(t)
+	srv, conn := newTestServer(require)
+	defer srv.close()
+
+	c := NewClient(Config{Nick: "chatto"})
+	err := connectClient(c, conn, func() {
+		srv.expect("NICK chatto")
+		srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+		srv.send(
+			":irc.test 001 chatto :Welcome",
+			":irc.test 005 chatto PREFIX=(qov)~@+ :are supported by this server",
+			":irc.test 376 chatto :End of /MOTD command.",
+		)
+	})
+	require.Nil(err)
+
+	updates := make(chan Event, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.Each(ctx, CHANNEL_UPDATED, func(e Event) {
+		updates <- e
+	})
+	// update writes the lines and waits for the channel to be updated by the last one
+	update := func(lines ...string) {
+		srv.send(lines...)
+		select {
+		case e := <-updates:
+			require.Equal("#chatto", e.Channel)
+		case <-time.After(time.Second):
+			require.Fail("channel update timed out")
+		}
+	}
+
+	update(":chatto!~chatto@chatto.host JOIN #chatto")
+	ch := c.Channel("#chatto")
+	require.NotNil(ch)
+	require.Len(c.JoinedChannels(), 1)
+
+	update(":irc.test 332 chatto #chatto :Welcome to Chatto")
+	update(":irc.test 333 chatto #chatto alice 1600000000")
+	// The members are only replaced once the list ends
+	update(
+		":irc.test 353 chatto = #chatto :~@chatto +alice bob",
+		":irc.test 366 chatto #chatto :End of /NAMES list.",
+	)
+	require.Equal(Topic{Text: "Welcome to Chatto", SetBy: "alice", SetAt: time.Unix(1600000000, 0)}, ch.Topic())
+	members := ch.Members()
+	require.Len(members, 3)
+	require.Equal(Member{Nick: "alice", Modes: "v"}, members[0])
+	require.Equal(Member{Nick: "chatto", Modes: "qo"}, members[2])
+	require.True(ch.IsOp("chatto"))
+	require.False(ch.IsOp("alice"))
+	require.True(ch.IsVoice("alice"))
+	require.False(ch.IsVoice("bob"))
+
+	update(":irc.test 324 chatto #chatto +ntk key")
+	key, ok := ch.Mode('k')
+	require.True(ok)
+	require.Equal("key", key)
+	require.Len(ch.Modes(), 3)
+
+	update(":chatto!~chatto@chatto.host MODE #chatto +o-k+b bob key *!*@bad.host")
+	require.True(ch.IsOp("bob"))
+	require.True(ch.HasMode("bob", 'o'))
+	_, ok = ch.Mode('k')
+	require.False(ok)
+	require.Equal([]string{"*!*@bad.host"}, ch.List('b'))
+
+	update(":alice!~alice@alice.host TOPIC #chatto :New topic")
+	require.Equal("New topic", ch.Topic().Text)
+	require.Equal("alice", ch.Topic().SetBy)
+
+	update(":dave!~dave@dave.host JOIN #chatto")
+	dave, ok := ch.Member("dave")
+	require.True(ok)
+	require.Equal(Member{Nick: "dave", User: "~dave", Host: "dave.host"}, dave)
+
+	update(":alice!~alice@alice.host NICK alicia")
+	require.False(ch.HasMember("alice"))
+	require.True(ch.IsVoice("alicia"))
+
+	update(":alicia!~alice@alice.host PART #chatto :Bye")
+	update(":chatto!~chatto@chatto.host KICK #chatto bob :Out")
+	update(":dave!~dave@dave.host QUIT :Quit")
+	members = ch.Members()
+	require.Len(members, 1)
+	require.Equal("chatto", members[0].Nick)
+
+	// Being kicked ourselves forgets the channel
+	update(":op!~op@op.host KICK #chatto chatto :Out")
+	require.Nil(c.Channel("#chatto"))
+	require.Len(c.JoinedChannels(), 0)
+
+	require.Nil(closeClient(c, srv))
+}
@@ -0,0 +1,102 @@
+package irc
+
+import (
+	"sort"
+	"strings"
+)
+
+// Tags holds the IRCv3 message tags of a message. A tag without a value is
+// stored with an empty string.
+type Tags map[string]string
+
+var (
+	tagEscaper = strings.NewReplacer(
+		";", "\\:",
+		" ", "\\s",
+		"\\", "\\\\",
+		"\r", "\\r",
+		"\n", "\\n",
+	)
+	tagUnescapes = map[byte]byte{
+		':':  ';',
+		's':  ' ',
+		'\\': '\\',
+		'r':  '\r',
+		'n':  '\n',
+	}
+)
+
+func (t Tags) Get(key string) (string, bool) {
+	v, ok := t[key]
+	return v, ok
+}
+
+func (t Tags) Has(key string) bool {
+	_, ok := t[key]
+	return ok
+}
+
+// String serializes the tags into their wire format without the leading '@'.
+// Keys are sorted so the output is deterministic.
+func (t Tags) String() string {
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			sb.WriteByte(';')
+		}
+		sb.WriteString(key)
+		if v := t[key]; v != "" {
+			sb.WriteByte('=')
+			sb.WriteString(tagEscaper.Replace(v))
+		}
+	}
+	return sb.String()
+}
+
+func parseTags(s string) Tags {
+	tags := make(Tags)
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		key, value := part, ""
+		if idx := strings.Index(part, "="); idx != -1 {
+			key, value = part[:idx], unescapeTagValue(part[idx+1:])
+		}
+		if key == "" {
+			continue
+		}
+		tags[key] = value
+	}
+	return tags
+}
+
+func unescapeTagValue(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		// A trailing backslash is dropped, an unknown escape drops the backslash
+		i++
+		if i >= len(s) {
+			break
+		}
+		if c, ok := tagUnescapes[s[i]]; ok {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
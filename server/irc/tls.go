@@ -0,0 +1,55 @@
+package irc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+)
+
+var ErrInvalidCACert = errors.New("no valid CA certificate found")
+
+// TLSConfig enables implicit TLS for Conn.
+type TLSConfig struct {
+	// Config is used as the base configuration when set
+	Config *tls.Config
+	// CAFile pins the certificate authorities trusted to sign the server certificate
+	CAFile string
+	// CertFile and KeyFile hold the client certificate used for CertFP
+	CertFile string
+	KeyFile  string
+}
+
+func (t *TLSConfig) build(addr string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if t.Config != nil {
+		cfg = t.Config.Clone()
+	}
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ServerName = host
+	}
+	if t.CAFile != "" {
+		pem, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, ErrInvalidCACert
+		}
+		cfg.RootCAs = pool
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,136 @@
+package irc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConnection(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+
+	serverCert, serverPEM, _ := createTestCertificate(require, "127.0.0.1")
+	clientCert, clientPEM, clientKeyPEM := createTestCertificate(require, "chatto")
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	require.Nil(ioutil.WriteFile(caFile, serverPEM, 0600))
+	require.Nil(ioutil.WriteFile(certFile, clientPEM, 0600))
+	require.Nil(ioutil.WriteFile(keyFile, clientKeyPEM, 0600))
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	require.Nil(err)
+	defer listener.Close()
+
+	c := NewConn(Config{
+		Nick: "chatto",
+		TLS: &TLSConfig{
+			CAFile:   caFile,
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		},
+	})
+	ch := make(chan error, 1)
+	go func() {
+		ch <- c.Connect(context.Background(), listener.Addr().String())
+	}()
+
+	conn, err := listener.Accept()
+	require.Nil(err)
+	tlsConn := conn.(*tls.Conn)
+	require.Nil(tlsConn.Handshake())
+	// The client certificate is presented for CertFP
+	peers := tlsConn.ConnectionState().PeerCertificates
+	require.Len(peers, 1)
+	require.Equal(sha256.Sum256(clientCert.Certificate[0]), sha256.Sum256(peers[0].Raw))
+
+	srv := newTestServerConn(require, conn)
+	srv.expect("NICK chatto")
+	srv.expect("USER chatto-irc 12 * :Chatto IRC client")
+	srv.send(
+		":irc.test 001 chatto :Welcome",
+		":irc.test 376 chatto :End of /MOTD command.",
+	)
+	require.Nil(<-ch)
+	require.True(c.Connected())
+	require.True(c.TLS())
+
+	go func() {
+		ch <- c.Close(context.Background())
+	}()
+	srv.expect(QUIT)
+	srv.close()
+	require.Nil(<-ch)
+}
+
+func TestTLSUntrusted(t *testing.T) {
+	require := require.New(t)
+
+	serverCert, _, _ := createTestCertificate(require, "127.0.0.1")
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+	})
+	require.Nil(err)
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	// Self-signed certificates are rejected unless pinned
+	c := NewConn(Config{
+		Nick: "chatto",
+		TLS:  &TLSConfig{},
+	})
+	err = c.Connect(context.Background(), listener.Addr().String())
+	require.NotNil(err)
+	require.False(c.Connected())
+}
+
+func createTestCertificate(require *require.Assertions, name string) (tls.Certificate, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.Nil(err)
+	return cert, certPEM, keyPEM
+}
@@ -0,0 +1,270 @@
+package irc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Directions of the transcript lines, from the client point of view
+const (
+	TRANSCRIPT_IN  = "<"
+	TRANSCRIPT_OUT = ">"
+)
+
+var ErrInvalidTranscript = errors.New("invalid transcript line")
+
+// TranscriptEntry is a raw line sent or received by the client.
+type TranscriptEntry struct {
+	// Time is zero when the transcript line has no timestamp
+	Time time.Time
+	Out  bool
+	Line string
+}
+
+func (e TranscriptEntry) String() string {
+	dir := TRANSCRIPT_IN
+	if e.Out {
+		dir = TRANSCRIPT_OUT
+	}
+	if e.Time.IsZero() {
+		return dir + " " + e.Line
+	}
+	return e.Time.UTC().Format(time.RFC3339Nano) + " " + dir + " " + e.Line
+}
+
+// ReadTranscript parses the transcript lines like "<timestamp> < <line>" for
+// the received lines and "<timestamp> > <line>" for the sent ones. The
+// timestamps are optional, while blank lines and lines starting with # are
+// skipped.
+func ReadTranscript(r io.Reader) ([]TranscriptEntry, error) {
+	entries := make([]TranscriptEntry, 0)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := parseTranscriptLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w %d: %s", ErrInvalidTranscript, n, line)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func parseTranscriptLine(line string) (TranscriptEntry, error) {
+	entry := TranscriptEntry{}
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) == 3 && parts[0] != TRANSCRIPT_IN && parts[0] != TRANSCRIPT_OUT {
+		t, err := time.Parse(time.RFC3339Nano, parts[0])
+		if err != nil {
+			return entry, err
+		}
+		entry.Time = t
+		parts = parts[1:]
+	} else {
+		parts = strings.SplitN(line, " ", 2)
+	}
+	if len(parts) < 2 {
+		return entry, ErrInvalidTranscript
+	}
+	switch parts[0] {
+	case TRANSCRIPT_IN:
+	case TRANSCRIPT_OUT:
+		entry.Out = true
+	default:
+		return entry, ErrInvalidTranscript
+	}
+	entry.Line = parts[len(parts)-1]
+	return entry, nil
+}
+
+// Recorder writes the lines going through the connection to a transcript.
+type Recorder struct {
+	rw io.ReadWriter
+
+	mu  sync.Mutex
+	w   io.Writer
+	in  bytes.Buffer
+	out bytes.Buffer
+	err error
+}
+
+func NewRecorder(rw io.ReadWriter, w io.Writer) *Recorder {
+	return &Recorder{rw: rw, w: w}
+}
+
+func (r *Recorder) Read(p []byte) (int, error) {
+	n, err := r.rw.Read(p)
+	r.record(&r.in, false, p[:n])
+	return n, err
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	// Recorded first so the replies can't get recorded before the line
+	r.record(&r.out, true, p)
+	return r.rw.Write(p)
+}
+
+// Err returns the first error writing the transcript.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(buf *bytes.Buffer, out bool, p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	buf.Write(p)
+	for {
+		i := bytes.IndexByte(buf.Bytes(), '\n')
+		if i < 0 {
+			return
+		}
+		line := strings.TrimRight(string(buf.Next(i+1)), "\r\n")
+		entry := TranscriptEntry{Time: time.Now(), Out: out, Line: line}
+		if _, err := io.WriteString(r.w, entry.String()+"\n"); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+}
+
+// Replayer plays a transcript back to the client as if it was the server.
+// The received lines following a sent one are only replayed once the client
+// sends the same line, and the replay ends like a connection closed by the
+// server once the whole transcript was played.
+type Replayer struct {
+	entries []TranscriptEntry
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// inPos is the next entry to read, outPos the one after the last sent line
+	// matched, since the client may send lines before reading the previous ones
+	inPos      int
+	outPos     int
+	pending    []byte
+	out        bytes.Buffer
+	written    []string
+	unexpected []string
+	closed     bool
+	done       chan struct{}
+}
+
+func NewReplayer(entries []TranscriptEntry) *Replayer {
+	r := &Replayer{entries: entries, done: make(chan struct{})}
+	r.cond = sync.NewCond(&r.mu)
+	if len(entries) <= 0 {
+		close(r.done)
+	}
+	return r
+}
+
+func (r *Replayer) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.pending) <= 0 {
+		if r.closed || r.inPos >= len(r.entries) {
+			return 0, io.EOF
+		}
+		entry := r.entries[r.inPos]
+		if entry.Out && r.inPos >= r.outPos {
+			// Wait for the client to send it
+			r.cond.Wait()
+			continue
+		}
+		if !entry.Out {
+			r.pending = []byte(entry.Line + "\r\n")
+		}
+		r.inPos++
+		if r.inPos == len(r.entries) {
+			close(r.done)
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *Replayer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+	r.out.Write(p)
+	for {
+		i := bytes.IndexByte(r.out.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimRight(string(r.out.Next(i+1)), "\r\n")
+		r.written = append(r.written, line)
+		next := r.outPos
+		for next < len(r.entries) && !r.entries[next].Out {
+			next++
+		}
+		if next < len(r.entries) && r.entries[next].Line == line {
+			r.outPos = next + 1
+		} else {
+			r.unexpected = append(r.unexpected, line)
+		}
+	}
+	r.cond.Broadcast()
+	return len(p), nil
+}
+
+// Close ends the replay, the client reading the end of the connection.
+func (r *Replayer) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	r.cond.Broadcast()
+	return nil
+}
+
+// Done returns a channel closed once the whole transcript was replayed.
+func (r *Replayer) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait waits for the whole transcript to be replayed, returning the line the
+// client was expected to send when the context is done first.
+func (r *Replayer) Wait(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := r.outPos; i < len(r.entries); i++ {
+		if r.entries[i].Out {
+			return fmt.Errorf("%w: expected %q", ctx.Err(), r.entries[i].Line)
+		}
+	}
+	return ctx.Err()
+}
+
+// Written returns the lines sent by the client.
+func (r *Replayer) Written() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.written...)
+}
+
+// Unexpected returns the lines sent by the client which weren't expected by
+// the transcript at that point.
+func (r *Replayer) Unexpected() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.unexpected...)
+}
@@ -0,0 +1,104 @@
+package irc
+
+import (
+	"bytes"
+	utesting "chatto/util/testing"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTranscript(t *testing.T) {
+	require := require.New(t)
+	entries, err := ReadTranscript(strings.NewReader(strings.Join([]string{
+		"# Registration",
+		"2020-09-13T12:26:40.5Z > NICK chatto",
+		"",
+		"< :irc.test 001 chatto :Welcome to IRC",
+	}, "\n")))
+	require.Nil(err)
+	require.Len(entries, 2)
+	require.True(entries[0].Out)
+	require.Equal("NICK chatto", entries[0].Line)
+	require.Equal(int64(1600000000), entries[0].Time.Unix())
+	require.Equal("2020-09-13T12:26:40.5Z > NICK chatto", entries[0].String())
+	require.False(entries[1].Out)
+	require.True(entries[1].Time.IsZero())
+	require.Equal(":irc.test 001 chatto :Welcome to IRC", entries[1].Line)
+
+	_, err = ReadTranscript(strings.NewReader("> NICK chatto\n? PING\n"))
+	require.True(errors.Is(err, ErrInvalidTranscript))
+}
+
+func TestRecordReplay(t *testing.T) {
+	require := require.New(t)
+	ctx, cancel := utesting.CreateTestingContext()
+	defer cancel()
+
+	cfg := Config{
+		Nick:      "chatto",
+		Flood:     FloodConfig{Burst: -1},
+		Keepalive: KeepaliveConfig{Interval: -1},
+	}
+	session := func(c *Client) {
+		require.Nil(c.Join(ctx, "#chatto"))
+		require.Nil(c.Privmsg(ctx, "#chatto", "Hello, world!"))
+		require.Nil(c.Close(ctx))
+	}
+
+	// Record a session against the fake server
+	srv := utesting.NewIRCServer()
+	defer srv.Close()
+	transcript := &bytes.Buffer{}
+	recorded := cfg
+	recorded.Transcript = transcript
+	c := NewClient(recorded)
+	require.Nil(c.Connect(ctx, srv.Pipe()))
+	session(c)
+
+	entries, err := ReadTranscript(transcript)
+	require.Nil(err)
+	require.True(entries[0].Out)
+	require.Equal("NICK chatto", entries[0].Line)
+	require.False(entries[0].Time.IsZero())
+	welcomed := false
+	for _, entry := range entries {
+		welcomed = welcomed || (!entry.Out && strings.HasPrefix(entry.Line, ":irc.test 001 chatto "))
+	}
+	require.True(welcomed)
+
+	// Replay it to a new client running the same session
+	replayer := NewReplayer(entries)
+	c = NewClient(cfg)
+	require.Nil(c.Connect(ctx, replayer))
+	session(c)
+	require.Nil(replayer.Wait(ctx))
+	require.Empty(replayer.Unexpected())
+	require.Equal([]string{
+		"NICK chatto",
+		"USER chatto-irc 12 * :Chatto IRC client",
+		"JOIN #chatto",
+		"PRIVMSG #chatto :Hello, world!",
+		"QUIT",
+	}, replayer.Written())
+
+	// Replays stop at the lines the client doesn't send
+	replayer = NewReplayer(entries)
+	c = NewClient(cfg)
+	require.Nil(c.Connect(ctx, replayer))
+	require.Nil(c.Privmsg(ctx, "#go", "Hi"))
+	waitCtx, waitCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer waitCancel()
+	err = replayer.Wait(waitCtx)
+	require.True(errors.Is(err, context.DeadlineExceeded))
+	require.Contains(err.Error(), `"JOIN #chatto"`)
+	require.Eventually(func() bool {
+		return len(replayer.Unexpected()) > 0
+	}, time.Second, 10*time.Millisecond)
+	require.Equal([]string{"PRIVMSG #go :Hi"}, replayer.Unexpected())
+	require.Nil(replayer.Close())
+}
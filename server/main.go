@@ -41,35 +41,36 @@ func main() {
 func serve(ctx context.Context, cfg Config) error {
 	conn := irc.NewConn(irc.Config{
 		Nick: cfg.Nick,
+		Reconnect: irc.ReconnectConfig{
+			CloseTimeout: 5 * time.Second,
+		},
 	})
 
 	conn.Each(ctx, irc.CONNECTED, func(e irc.Event) {
 		log.Infof("Connected to IRC server %s", cfg.Addr)
 	})
 	conn.Each(ctx, irc.DISCONNECTED, func(e irc.Event) {
-		log.Infof("Disconnected from IRC server %s", cfg.Addr)
+		if e.Error != nil {
+			log.Warnf("Disconnected from IRC server %s: %+v", cfg.Addr, e.Error)
+		} else {
+			log.Infof("Disconnected from IRC server %s", cfg.Addr)
+		}
+	})
+	// Channels joined afterwards are rejoined automatically on reconnect
+	conn.Once(ctx, irc.CONNECTED, func(e irc.Event) {
+		if err := conn.Join(ctx, cfg.Channel); err != nil {
+			log.Errorf("Failed to join channel %s: %+v", cfg.Channel, err)
+		}
 	})
 
 	handler := ircHandler.New(ctx)
-	conn.Each(ctx, irc.JOIN, handler.Join)
-	conn.Each(ctx, irc.INVITE, handler.Invite)
-	conn.Each(ctx, irc.KICK, handler.Kick)
-	conn.Each(ctx, irc.PRIVMSG, handler.Message)
-
-	if err := conn.Connect(ctx, cfg.Addr); err != nil {
-		return fmt.Errorf("failed to connect: %+v", err)
-	}
-	if err := conn.Join(ctx, cfg.Channel); err != nil {
-		return fmt.Errorf("failed to join channel: %+v", err)
-	}
-
-	<-ctx.Done()
+	conn.OnJoin(ctx, handler.Join)
+	conn.OnInvite(ctx, handler.Invite)
+	conn.OnKick(ctx, handler.Kick)
+	conn.OnPrivmsg(ctx, handler.Message)
 
-	log.Info("Terminating connection...")
-	closeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := conn.Close(closeCtx); err != nil {
-		return fmt.Errorf("failed to terminate connection: %+v", err)
+	if err := conn.Run(ctx, cfg.Addr); err != nil {
+		return fmt.Errorf("connection terminated: %+v", err)
 	}
 	log.Info("Connection terminated.")
 
@@ -13,16 +13,18 @@ type Observable struct {
 	ch chan Item
 
 	nextId    int
-	observers map[int]chan<- Item
+	observers map[int]subscription
 	cancel    context.CancelFunc
-	open      bool
+	// closed is closed along with the observable so that Notify doesn't block
+	closed chan struct{}
+	open   bool
 }
 
 func NewObservable() *Observable {
 	obs := &Observable{
 		ch:        make(chan Item),
 		nextId:    0,
-		observers: make(map[int]chan<- Item),
+		observers: make(map[int]subscription),
 	}
 	obs.Open()
 	return obs
@@ -34,7 +36,7 @@ func (o *Observable) Observe() (<-chan Item, int) {
 	o.nextId++
 	id := o.nextId
 	ch := make(chan Item, 1)
-	o.observers[id] = ch
+	o.observers[id] = subscription{ch: ch, removed: make(chan struct{})}
 	return ch, id
 }
 
@@ -46,16 +48,38 @@ func (o *Observable) Once(ctx context.Context, handler ObserverFunc) *Observer {
 	})
 }
 
+// Each runs the handler with the items in order, queueing them meanwhile so
+// that a slow handler doesn't block the notifier nor the other observers.
+// The queue is unbounded and applies no back-pressure: a handler which never
+// keeps up holds on to every item notified until its context is done.
 func (o *Observable) Each(ctx context.Context, handler ObserverFunc) *Observer {
 	ch, id := o.Observe()
 	loopCtx, cancel := context.WithCancel(ctx)
 	observer := NewObserver(o, id, cancel)
+	queue := newItemQueue()
 	go func() {
-		defer observer.Remove()
 		for {
 			select {
 			case item := <-ch:
-				go handler(item)
+				queue.push(item)
+			case <-loopCtx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		defer observer.Remove()
+		for {
+			select {
+			case <-queue.ready:
+				for _, item := range queue.take() {
+					// Stop right away once the context is done, otherwise Once could
+					// end up handling a pending item as well
+					handler(item)
+					if loopCtx.Err() != nil {
+						return
+					}
+				}
 			case <-loopCtx.Done():
 				return
 			}
@@ -64,14 +88,25 @@ func (o *Observable) Each(ctx context.Context, handler ObserverFunc) *Observer {
 	return observer
 }
 
+// Notify hands the item to the observers, dropping it once the observable is
+// closed.
 func (o *Observable) Notify(item Item) {
-	o.ch <- item
+	o.mu.RLock()
+	closed := o.closed
+	o.mu.RUnlock()
+	select {
+	case o.ch <- item:
+	case <-closed:
+	}
 }
 
 func (o *Observable) Remove(id int) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	delete(o.observers, id)
+	if sub, ok := o.observers[id]; ok {
+		delete(o.observers, id)
+		close(sub.removed)
+	}
 }
 
 func (o *Observable) Open() {
@@ -82,6 +117,7 @@ func (o *Observable) Open() {
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	o.cancel = cancel
+	o.closed = make(chan struct{})
 	o.wg.Add(1)
 	go o.notifyLoop(ctx)
 	o.open = true
@@ -89,14 +125,19 @@ func (o *Observable) Open() {
 
 func (o *Observable) Close() {
 	o.mu.Lock()
-	defer o.mu.Unlock()
 	if !o.open {
+		o.mu.Unlock()
 		return
 	}
-	o.cancel()
-	o.wg.Wait()
+	cancel := o.cancel
 	o.cancel = nil
+	close(o.closed)
 	o.open = false
+	o.mu.Unlock()
+
+	// Wait outside of the lock since the notify loop needs it to read the observers
+	cancel()
+	o.wg.Wait()
 }
 
 func (o *Observable) notifyLoop(ctx context.Context) {
@@ -104,11 +145,71 @@ func (o *Observable) notifyLoop(ctx context.Context) {
 	for {
 		select {
 		case item := <-o.ch:
-			for _, ch := range o.observers {
-				ch <- item
+			for _, sub := range o.snapshot() {
+				// Deliver right away when possible, so the items notified just
+				// before closing aren't dropped
+				select {
+				case sub.ch <- item:
+					continue
+				default:
+				}
+				// An observer removed meanwhile won't read it anymore
+				select {
+				case sub.ch <- item:
+				case <-sub.removed:
+				case <-ctx.Done():
+					return
+				}
 			}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+func (o *Observable) snapshot() []subscription {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	observers := make([]subscription, 0, len(o.observers))
+	for _, sub := range o.observers {
+		observers = append(observers, sub)
+	}
+	return observers
+}
+
+// subscription is the channel of an observer, removed being closed once it
+// stops reading it.
+type subscription struct {
+	ch      chan<- Item
+	removed chan struct{}
+}
+
+// itemQueue is an unbounded queue of the items waiting for a handler, see Each.
+type itemQueue struct {
+	mu    sync.Mutex
+	items []Item
+	// ready holds a signal while there are items to take
+	ready chan struct{}
+}
+
+func newItemQueue() *itemQueue {
+	return &itemQueue{ready: make(chan struct{}, 1)}
+}
+
+func (q *itemQueue) push(item Item) {
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	q.mu.Unlock()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (q *itemQueue) take() []Item {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
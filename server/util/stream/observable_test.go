@@ -63,6 +63,81 @@ func TestObservable(t *testing.T) {
 		assertObservableValue(require, ch, timeout, expected)
 		assertObservableTimeout(require, ch, timeout)
 	}
+
+	// Test a blocked handler doesn't block the notifier
+	{
+		obs := NewObservable()
+		defer obs.Close()
+		timeout := time.After(1 * time.Second)
+		release := make(chan struct{})
+		ch := make(chan Item, 3)
+		obs.Each(ctx, func(item Item) {
+			<-release
+			ch <- item
+		})
+		notified := make(chan struct{})
+		go func() {
+			for i := 0; i < 3; i++ {
+				obs.Notify(Item{V: i})
+			}
+			close(notified)
+		}()
+		select {
+		case <-notified:
+		case <-timeout:
+			require.FailNow("Expected to notify while the handler is blocked")
+		}
+		close(release)
+		assertObservableValue(require, ch, timeout, 0)
+		assertObservableValue(require, ch, timeout, 1)
+		assertObservableValue(require, ch, timeout, 2)
+	}
+
+	// Test removing an observer unblocks the notifier delivering to it
+	{
+		obs := NewObservable()
+		defer obs.Close()
+		timeout := time.After(1 * time.Second)
+		_, id := obs.Observe()
+		// The first item fills the buffer and the second blocks the notifier
+		obs.Notify(Item{V: 0})
+		obs.Notify(Item{V: 1})
+		notified := make(chan struct{})
+		go func() {
+			obs.Notify(Item{V: 2})
+			close(notified)
+		}()
+		select {
+		case <-notified:
+			require.FailNow("Expected the notifier to be blocked")
+		case <-time.After(50 * time.Millisecond):
+		}
+		obs.Remove(id)
+		select {
+		case <-notified:
+		case <-timeout:
+			require.FailNow("Expected to notify once the observer is removed")
+		}
+	}
+
+	// Test notifying a closed observable drops the item
+	{
+		obs := NewObservable()
+		timeout := time.After(1 * time.Second)
+		ch, _ := obs.Observe()
+		obs.Close()
+		notified := make(chan struct{})
+		go func() {
+			obs.Notify(Item{V: expected})
+			close(notified)
+		}()
+		select {
+		case <-notified:
+		case <-timeout:
+			require.FailNow("Expected to notify once the observable is closed")
+		}
+		assertObservableTimeout(require, ch, time.After(50*time.Millisecond))
+	}
 }
 
 func assertObservableValue(
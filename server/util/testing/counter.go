@@ -3,23 +3,19 @@ package testing
 import "sync/atomic"
 
 type Counter struct {
-	value *atomic.Value
+	value *int64
 }
 
 func NewCounter() *Counter {
 	return &Counter{
-		value: &atomic.Value{},
+		value: new(int64),
 	}
 }
 
 func (c *Counter) Add() {
-	x := c.Int()
-	c.value.Store(x + 1)
+	atomic.AddInt64(c.value, 1)
 }
 
 func (c *Counter) Int() int {
-	if x, ok := c.value.Load().(int); ok {
-		return x
-	}
-	return 0
+	return int(atomic.LoadInt64(c.value))
 }
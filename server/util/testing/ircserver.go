@@ -0,0 +1,751 @@
+package testing
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIRCServerName = "irc.test"
+	ircWriteTimeout      = time.Second
+)
+
+var (
+	ErrIRCTimeout       = errors.New("timed out waiting on the IRC server")
+	ErrIRCSessionClosed = errors.New("IRC session closed")
+)
+
+// IRCMessage is a line received by the IRC server.
+type IRCMessage struct {
+	Raw     string
+	Prefix  string
+	Command string
+	Params  []string
+}
+
+// ParseIRCMessage parses the line, ignoring its tags.
+func ParseIRCMessage(line string) IRCMessage {
+	msg := IRCMessage{Raw: line}
+	if strings.HasPrefix(line, "@") {
+		line = afterSpace(line)
+	}
+	if strings.HasPrefix(line, ":") {
+		msg.Prefix = strings.SplitN(line[1:], " ", 2)[0]
+		line = afterSpace(line)
+	}
+	for line != "" {
+		if strings.HasPrefix(line, ":") {
+			msg.Params = append(msg.Params, line[1:])
+			break
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if parts[0] != "" {
+			if msg.Command == "" {
+				msg.Command = strings.ToUpper(parts[0])
+			} else {
+				msg.Params = append(msg.Params, parts[0])
+			}
+		}
+		if len(parts) < 2 {
+			break
+		}
+		line = parts[1]
+	}
+	return msg
+}
+
+func afterSpace(s string) string {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return strings.TrimLeft(s[i+1:], " ")
+	}
+	return ""
+}
+
+// Param returns the i-th parameter, or an empty string when missing.
+func (m IRCMessage) Param(i int) string {
+	if i < len(m.Params) {
+		return m.Params[i]
+	}
+	return ""
+}
+
+// IRCHandlerFunc scripts the answer to a command, returning true to skip the
+// default handling, e.g. to stop answering PINGs or reply with an error.
+type IRCHandlerFunc func(s *IRCSession, msg IRCMessage) bool
+
+// IRCServer is an in-process IRC server speaking enough of the protocol to
+// test the clients hermetically: registration with CAP and PASS, PING, JOIN,
+// PART, TOPIC, PRIVMSG, NOTICE, NICK and QUIT. The connections are served
+// either over net.Pipe or a loopback listener.
+type IRCServer struct {
+	// Name is the source of the server replies, defaults to "irc.test"
+	Name string
+	// Caps are advertised to CAP LS and acknowledged when requested
+	Caps []string
+	// ISupport tokens are sent with RPL_ISUPPORT once registered
+	ISupport []string
+	// Password is required with PASS when set
+	Password string
+	// Raw disables the default handling, leaving the tests to script every
+	// reply through the sessions
+	Raw bool
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	handlers map[string]IRCHandlerFunc
+	sessions map[*IRCSession]struct{}
+	channels map[string]*ircChannel
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+type ircChannel struct {
+	name    string
+	topic   string
+	members []*IRCSession
+	ops     map[*IRCSession]bool
+}
+
+func NewIRCServer() *IRCServer {
+	s := &IRCServer{
+		Name:     defaultIRCServerName,
+		handlers: make(map[string]IRCHandlerFunc),
+		sessions: make(map[*IRCSession]struct{}),
+		channels: make(map[string]*ircChannel),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Handle scripts the command, the handler being called before the default
+// handling. Handlers may send lines but must not Exec commands.
+func (s *IRCServer) Handle(command string, handler IRCHandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[strings.ToUpper(command)] = handler
+}
+
+// Pipe returns the client end of a new connection served through net.Pipe.
+func (s *IRCServer) Pipe() net.Conn {
+	client, server := net.Pipe()
+	s.Serve(server)
+	return client
+}
+
+// Listen serves the connections accepted on a loopback address until closed.
+func (s *IRCServer) Listen() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.Serve(conn)
+		}
+	}()
+	return listener.Addr().String(), nil
+}
+
+// Close stops listening and drops every connection.
+func (s *IRCServer) Close() {
+	s.mu.Lock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	sessions := make([]*IRCSession, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		sess.Close()
+	}
+	s.wg.Wait()
+}
+
+// AddUser adds a registered user without connection, whose commands are run
+// with Exec, e.g. to have other users join or talk in channels.
+func (s *IRCServer) AddUser(nick string) *IRCSession {
+	sess := newIRCSession(s, nil)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.nick = nick
+	sess.user = nick
+	sess.registered = true
+	s.sessions[sess] = struct{}{}
+	s.cond.Broadcast()
+	return sess
+}
+
+// Session returns the registered session using the nick.
+func (s *IRCServer) Session(nick string) (*IRCSession, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.findNick(nick)
+	return sess, sess != nil
+}
+
+// WaitSession waits for a client to register with the nick.
+func (s *IRCServer) WaitSession(nick string, timeout time.Duration) (*IRCSession, error) {
+	var sess *IRCSession
+	ok := waitCond(s.cond, timeout, func() bool {
+		sess = s.findNick(nick)
+		return sess != nil
+	})
+	if !ok {
+		return nil, ErrIRCTimeout
+	}
+	return sess, nil
+}
+
+// Serve serves the server end of a connection accepted by the test.
+func (s *IRCServer) Serve(conn net.Conn) *IRCSession {
+	sess := newIRCSession(s, conn)
+	s.mu.Lock()
+	s.sessions[sess] = struct{}{}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			sess.record(line)
+			s.dispatch(sess, ParseIRCMessage(line))
+		}
+		sess.Close()
+		s.mu.Lock()
+		s.quit(sess, "Connection closed")
+		s.mu.Unlock()
+	}()
+	return sess
+}
+
+func (s *IRCServer) dispatch(sess *IRCSession, msg IRCMessage) {
+	s.mu.Lock()
+	handler := s.handlers[msg.Command]
+	s.mu.Unlock()
+	if handler != nil && handler(sess, msg) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[sess]; !ok || s.Raw {
+		return
+	}
+	switch msg.Command {
+	case "":
+	case "CAP":
+		s.handleCap(sess, msg)
+	case "PASS":
+		sess.pass = msg.Param(0)
+	case "NICK":
+		s.handleNick(sess, msg)
+	case "USER":
+		if len(msg.Params) < 4 {
+			sess.numeric("461", "USER", "Not enough parameters")
+			return
+		}
+		sess.user, sess.realname = msg.Params[0], msg.Params[3]
+		s.register(sess)
+	case "PING":
+		sess.Send(fmt.Sprintf(":%s PONG %s :%s", s.Name, s.Name, msg.Param(0)))
+	case "PONG":
+	case "QUIT":
+		reason := "Quit: " + msg.Param(0)
+		sess.Send("ERROR :Closing link (" + reason + ")")
+		s.quit(sess, reason)
+		sess.Close()
+	default:
+		if !sess.registered {
+			sess.numeric("451", "You have not registered")
+			return
+		}
+		s.handleCommand(sess, msg)
+	}
+}
+
+func (s *IRCServer) handleCommand(sess *IRCSession, msg IRCMessage) {
+	switch msg.Command {
+	case "JOIN":
+		if len(msg.Params) < 1 {
+			sess.numeric("461", "JOIN", "Not enough parameters")
+			return
+		}
+		for _, name := range strings.Split(msg.Params[0], ",") {
+			s.join(sess, name)
+		}
+	case "PART":
+		if len(msg.Params) < 1 {
+			sess.numeric("461", "PART", "Not enough parameters")
+			return
+		}
+		for _, name := range strings.Split(msg.Params[0], ",") {
+			s.part(sess, name, msg.Param(1))
+		}
+	case "TOPIC":
+		s.handleTopic(sess, msg)
+	case "PRIVMSG", "NOTICE":
+		s.handleMessage(sess, msg)
+	default:
+		sess.numeric("421", msg.Command, "Unknown command")
+	}
+}
+
+func (s *IRCServer) handleCap(sess *IRCSession, msg IRCMessage) {
+	switch strings.ToUpper(msg.Param(0)) {
+	case "LS":
+		sess.negotiating = true
+		sess.Send(fmt.Sprintf(":%s CAP %s LS :%s", s.Name, sess.target(), strings.Join(s.Caps, " ")))
+	case "REQ":
+		sess.negotiating = true
+		supported := make(map[string]bool, len(s.Caps))
+		for _, c := range s.Caps {
+			supported[c] = true
+		}
+		requested := strings.Fields(msg.Param(1))
+		for _, c := range requested {
+			if !supported[c] {
+				sess.Send(fmt.Sprintf(":%s CAP %s NAK :%s", s.Name, sess.target(), msg.Param(1)))
+				return
+			}
+		}
+		sess.caps = append(sess.caps, requested...)
+		sess.Send(fmt.Sprintf(":%s CAP %s ACK :%s", s.Name, sess.target(), msg.Param(1)))
+	case "LIST":
+		sess.Send(fmt.Sprintf(":%s CAP %s LIST :%s", s.Name, sess.target(), strings.Join(sess.caps, " ")))
+	case "END":
+		sess.negotiating = false
+		s.register(sess)
+	}
+}
+
+func (s *IRCServer) handleNick(sess *IRCSession, msg IRCMessage) {
+	nick := msg.Param(0)
+	switch {
+	case nick == "":
+		sess.numeric("431", "No nickname given")
+	case strings.ContainsAny(nick[:1], "#&:$0123456789-") || strings.ContainsAny(nick, " ,*?!@."):
+		sess.numeric("432", nick, "Erroneous nickname")
+	case s.findNick(nick) != nil && s.findNick(nick) != sess:
+		sess.numeric("433", nick, "Nickname is already in use")
+	case !sess.registered:
+		sess.nick = nick
+		s.register(sess)
+	default:
+		line := fmt.Sprintf(":%s NICK %s", sess.prefix(), nick)
+		sess.Send(line)
+		s.sendPeers(sess, line)
+		sess.nick = nick
+	}
+}
+
+// register welcomes the session once NICK, USER and the capability
+// negotiation are done.
+func (s *IRCServer) register(sess *IRCSession) {
+	if sess.registered || sess.nick == "" || sess.user == "" || sess.negotiating {
+		return
+	}
+	if s.Password != "" && sess.pass != s.Password {
+		sess.numeric("464", "Password incorrect")
+		sess.Send("ERROR :Closing link (Bad password)")
+		s.quit(sess, "Bad password")
+		sess.Close()
+		return
+	}
+	sess.registered = true
+	sess.numeric("001", "Welcome to the Internet Relay Network "+sess.prefix())
+	if len(s.ISupport) > 0 {
+		sess.numeric("005", append(append([]string{}, s.ISupport...), "are supported by this server")...)
+	}
+	sess.numeric("422", "MOTD File is missing")
+	s.cond.Broadcast()
+}
+
+func (s *IRCServer) join(sess *IRCSession, name string) {
+	if !strings.HasPrefix(name, "#") && !strings.HasPrefix(name, "&") {
+		sess.numeric("403", name, "No such channel")
+		return
+	}
+	ch, ok := s.channels[foldIRC(name)]
+	if !ok {
+		ch = &ircChannel{name: name, ops: map[*IRCSession]bool{sess: true}}
+		s.channels[foldIRC(name)] = ch
+	}
+	if ch.has(sess) {
+		return
+	}
+	ch.members = append(ch.members, sess)
+	ch.send(fmt.Sprintf(":%s JOIN %s", sess.prefix(), ch.name), nil)
+	if ch.topic != "" {
+		sess.numeric("332", ch.name, ch.topic)
+	}
+	names := make([]string, 0, len(ch.members))
+	for _, member := range ch.members {
+		if ch.ops[member] {
+			names = append(names, "@"+member.nick)
+		} else {
+			names = append(names, member.nick)
+		}
+	}
+	sess.numeric("353", "=", ch.name, strings.Join(names, " "))
+	sess.numeric("366", ch.name, "End of /NAMES list.")
+}
+
+func (s *IRCServer) part(sess *IRCSession, name string, reason string) {
+	ch, ok := s.channels[foldIRC(name)]
+	if !ok {
+		sess.numeric("403", name, "No such channel")
+		return
+	}
+	if !ch.has(sess) {
+		sess.numeric("442", ch.name, "You're not on that channel")
+		return
+	}
+	line := fmt.Sprintf(":%s PART %s", sess.prefix(), ch.name)
+	if reason != "" {
+		line += " :" + reason
+	}
+	ch.send(line, nil)
+	s.leave(ch, sess)
+}
+
+func (s *IRCServer) handleTopic(sess *IRCSession, msg IRCMessage) {
+	if len(msg.Params) < 1 {
+		sess.numeric("461", "TOPIC", "Not enough parameters")
+		return
+	}
+	ch, ok := s.channels[foldIRC(msg.Params[0])]
+	if !ok || !ch.has(sess) {
+		sess.numeric("442", msg.Params[0], "You're not on that channel")
+		return
+	}
+	if len(msg.Params) < 2 {
+		if ch.topic == "" {
+			sess.numeric("331", ch.name, "No topic is set")
+		} else {
+			sess.numeric("332", ch.name, ch.topic)
+		}
+		return
+	}
+	ch.topic = msg.Params[1]
+	ch.send(fmt.Sprintf(":%s TOPIC %s :%s", sess.prefix(), ch.name, ch.topic), nil)
+}
+
+func (s *IRCServer) handleMessage(sess *IRCSession, msg IRCMessage) {
+	// NOTICEs never get automatic replies
+	notice := msg.Command == "NOTICE"
+	if len(msg.Params) < 1 {
+		if !notice {
+			sess.numeric("411", "No recipient given ("+msg.Command+")")
+		}
+		return
+	}
+	if len(msg.Params) < 2 || msg.Params[1] == "" {
+		if !notice {
+			sess.numeric("412", "No text to send")
+		}
+		return
+	}
+	for _, target := range strings.Split(msg.Params[0], ",") {
+		line := fmt.Sprintf(":%s %s %s :%s", sess.prefix(), msg.Command, target, msg.Params[1])
+		if ch, ok := s.channels[foldIRC(target)]; ok {
+			if !ch.has(sess) {
+				if !notice {
+					sess.numeric("404", ch.name, "Cannot send to channel")
+				}
+				continue
+			}
+			ch.send(line, sess)
+		} else if peer := s.findNick(target); peer != nil {
+			peer.Send(line)
+		} else if !notice {
+			sess.numeric("401", target, "No such nick/channel")
+		}
+	}
+}
+
+// quit removes the session from the server, telling the users sharing a
+// channel with it.
+func (s *IRCServer) quit(sess *IRCSession, reason string) {
+	if _, ok := s.sessions[sess]; !ok {
+		return
+	}
+	if sess.registered {
+		s.sendPeers(sess, fmt.Sprintf(":%s QUIT :%s", sess.prefix(), reason))
+	}
+	for _, ch := range s.channels {
+		if ch.has(sess) {
+			s.leave(ch, sess)
+		}
+	}
+	delete(s.sessions, sess)
+	s.cond.Broadcast()
+}
+
+func (s *IRCServer) leave(ch *ircChannel, sess *IRCSession) {
+	for i, member := range ch.members {
+		if member == sess {
+			ch.members = append(ch.members[:i], ch.members[i+1:]...)
+			break
+		}
+	}
+	delete(ch.ops, sess)
+	if len(ch.members) <= 0 {
+		delete(s.channels, foldIRC(ch.name))
+	}
+}
+
+// sendPeers sends the line once to every user sharing a channel with the session.
+func (s *IRCServer) sendPeers(sess *IRCSession, line string) {
+	sent := map[*IRCSession]bool{sess: true}
+	for _, ch := range s.channels {
+		if !ch.has(sess) {
+			continue
+		}
+		for _, member := range ch.members {
+			if !sent[member] {
+				sent[member] = true
+				member.Send(line)
+			}
+		}
+	}
+}
+
+func (s *IRCServer) findNick(nick string) *IRCSession {
+	for sess := range s.sessions {
+		if sess.nick != "" && foldIRC(sess.nick) == foldIRC(nick) {
+			return sess
+		}
+	}
+	return nil
+}
+
+func (ch *ircChannel) has(sess *IRCSession) bool {
+	for _, member := range ch.members {
+		if member == sess {
+			return true
+		}
+	}
+	return false
+}
+
+// send sends the line to the channel members except the given one.
+func (ch *ircChannel) send(line string, except *IRCSession) {
+	for _, member := range ch.members {
+		if member != except {
+			member.Send(line)
+		}
+	}
+}
+
+// IRCSession is a client connected to the IRC server, or a user added with
+// AddUser which has no connection.
+type IRCSession struct {
+	server *IRCServer
+	conn   net.Conn
+
+	// Guarded by the server lock
+	nick        string
+	user        string
+	realname    string
+	pass        string
+	caps        []string
+	negotiating bool
+	registered  bool
+
+	wmu sync.Mutex
+
+	rmu      sync.Mutex
+	rcond    *sync.Cond
+	received []string
+	next     int
+	closed   bool
+}
+
+func newIRCSession(s *IRCServer, conn net.Conn) *IRCSession {
+	sess := &IRCSession{server: s, conn: conn}
+	sess.rcond = sync.NewCond(&sess.rmu)
+	return sess
+}
+
+// Nick returns the current nick of the session.
+func (s *IRCSession) Nick() string {
+	s.server.mu.Lock()
+	defer s.server.mu.Unlock()
+	return s.nick
+}
+
+// Prefix returns the nick!user@host source of the session.
+func (s *IRCSession) Prefix() string {
+	s.server.mu.Lock()
+	defer s.server.mu.Unlock()
+	return s.prefix()
+}
+
+func (s *IRCSession) prefix() string {
+	return fmt.Sprintf("%s!~%s@localhost", s.nick, s.user)
+}
+
+// Exec runs the command as if it was sent by the session.
+func (s *IRCSession) Exec(line string) {
+	s.server.dispatch(s, ParseIRCMessage(line))
+}
+
+// Send writes the raw lines to the client, which is a no-op for the users
+// without connection.
+func (s *IRCSession) Send(lines ...string) {
+	if s.conn == nil {
+		return
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	for _, line := range lines {
+		// Don't hang on clients which stopped reading
+		_ = s.conn.SetWriteDeadline(time.Now().Add(ircWriteTimeout))
+		if _, err := s.conn.Write([]byte(line + "\r\n")); err != nil {
+			return
+		}
+	}
+}
+
+func (s *IRCSession) numeric(code string, params ...string) {
+	line := fmt.Sprintf(":%s %s %s", s.server.Name, code, s.target())
+	for i, param := range params {
+		if i == len(params)-1 {
+			line += " :" + param
+		} else {
+			line += " " + param
+		}
+	}
+	s.Send(line)
+}
+
+// target is the nick used in the replies, "*" until one is set.
+func (s *IRCSession) target() string {
+	if s.nick == "" {
+		return "*"
+	}
+	return s.nick
+}
+
+// Close drops the connection abruptly.
+func (s *IRCSession) Close() {
+	s.rmu.Lock()
+	s.closed = true
+	s.rcond.Broadcast()
+	s.rmu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+func (s *IRCSession) record(line string) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	s.received = append(s.received, line)
+	s.rcond.Broadcast()
+}
+
+// Received returns all the lines received from the client.
+func (s *IRCSession) Received() []string {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	return append([]string{}, s.received...)
+}
+
+// Next returns the next line received from the client which wasn't returned yet.
+func (s *IRCSession) Next(timeout time.Duration) (IRCMessage, error) {
+	var msg IRCMessage
+	var err error
+	ok := waitCond(s.rcond, timeout, func() bool {
+		if s.next < len(s.received) {
+			msg = ParseIRCMessage(s.received[s.next])
+			s.next++
+			return true
+		}
+		if s.closed {
+			err = ErrIRCSessionClosed
+			return true
+		}
+		return false
+	})
+	if !ok {
+		return msg, ErrIRCTimeout
+	}
+	return msg, err
+}
+
+// WaitFor skips the received lines up to the next command.
+func (s *IRCSession) WaitFor(command string, timeout time.Duration) (IRCMessage, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		msg, err := s.Next(time.Until(deadline))
+		if err != nil || msg.Command == strings.ToUpper(command) {
+			return msg, err
+		}
+	}
+}
+
+// waitCond waits on the condition, whose lock is held while checking, until
+// the check passes or the timeout expires.
+func waitCond(cond *sync.Cond, timeout time.Duration, check func() bool) bool {
+	timer := time.AfterFunc(timeout, func() {
+		cond.L.Lock()
+		defer cond.L.Unlock()
+		cond.Broadcast()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+
+	cond.L.Lock()
+	defer cond.L.Unlock()
+	for !check() {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		cond.Wait()
+	}
+	return true
+}
+
+// foldIRC folds the name with the rfc1459 casemapping.
+func foldIRC(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		case r == '[':
+			return '{'
+		case r == ']':
+			return '}'
+		case r == '\\':
+			return '|'
+		case r == '~':
+			return '^'
+		}
+		return r
+	}, name)
+}